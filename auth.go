@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"less-advanced-security/github"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Credentials for GitHub, from flags. Secrets are named by the environment variable holding them (rather than passed
+// as flag values) so they never appear in process listings or CI logs.
+type credentials struct {
+	appID, installID int
+	// a path to the app's private key, or - for stdin
+	keyPath string
+	// an environment variable holding the app's private key, as PEM or base64-encoded PEM
+	keyEnv string
+	// an environment variable holding a token (e.g., GITHUB_TOKEN), used instead of the app when set
+	tokenEnv string
+}
+
+// Build the client configuration for the credentials, reading any key or token they refer to.
+func (c credentials) clientConfiguration(stdin io.Reader) (github.ClientConfiguration, error) {
+	if c.tokenEnv != "" {
+		token := strings.TrimSpace(os.Getenv(c.tokenEnv))
+		if token == "" {
+			return github.ClientConfiguration{}, errors.Errorf("--token_env names %s, which is not set", c.tokenEnv)
+		}
+		return github.ClientConfiguration{AppID: int64(c.appID), Token: token}, nil
+	}
+
+	if c.appID <= 0 {
+		return github.ClientConfiguration{}, errors.New("--app_id is required to authenticate as a GitHub App (or set --token_env to use a token)")
+	}
+	// without an installation id, the installation is discovered from the repository
+	configuration := github.ClientConfiguration{AppID: int64(c.appID), InstallationID: int64(c.installID)}
+	switch {
+	case c.keyEnv != "":
+		key, err := decodeKey(os.Getenv(c.keyEnv))
+		if err != nil {
+			return github.ClientConfiguration{}, errors.Wrapf(err, "invalid key in %s", c.keyEnv)
+		}
+		configuration.AppKey = key
+	case c.keyPath == "-":
+		key, err := io.ReadAll(stdin)
+		if err != nil {
+			return github.ClientConfiguration{}, errors.Wrap(err, "failed to read the key from stdin")
+		}
+		if configuration.AppKey, err = decodeKey(string(key)); err != nil {
+			return github.ClientConfiguration{}, errors.Wrap(err, "invalid key on stdin")
+		}
+	case c.keyPath != "":
+		configuration.AppKeyPath = c.keyPath
+	default:
+		return github.ClientConfiguration{}, errors.New("one of --key_path or --key_env is required to authenticate as a GitHub App")
+	}
+	return configuration, nil
+}
+
+// Decode a private key which is either PEM or base64-encoded PEM (which fits in a single-line secret).
+func decodeKey(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.New("the key is empty")
+	}
+	if strings.HasPrefix(key, "-----BEGIN") {
+		return []byte(key), nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.New("the key is neither PEM nor base64-encoded PEM")
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(decoded)), "-----BEGIN") {
+		return nil, errors.New("the base64-decoded key is not PEM")
+	}
+	return decoded, nil
+}
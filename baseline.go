@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"less-advanced-security/sarif"
+	"sort"
+	"strings"
+)
+
+// The result of comparing a check's results against those from the pull request's base commit.
+type baselineComparison struct {
+	// results not found on the base commit
+	newResults []*sarif.Result
+	// results also found on the base commit
+	unchangedResults []*sarif.Result
+	// results found on the base commit but no longer reported (i.e., fixed)
+	absentResults []*sarif.Result
+}
+
+// Classify results as new, unchanged, or absent relative to the results of a scan of the base commit. Results are
+// matched by key, counting duplicates (three identical results against two on the base commit yields one new result).
+func compareToBaseline(results []*sarif.Result, baselineResults []*sarif.Result) baselineComparison {
+	baselineKeyToResults := make(map[string][]*sarif.Result)
+	for _, result := range baselineResults {
+		if result == nil {
+			continue
+		}
+		key := baselineKey(result)
+		baselineKeyToResults[key] = append(baselineKeyToResults[key], result)
+	}
+
+	comparison := baselineComparison{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		key := baselineKey(result)
+		if matches := baselineKeyToResults[key]; len(matches) > 0 {
+			baselineKeyToResults[key] = matches[1:]
+			comparison.unchangedResults = append(comparison.unchangedResults, result)
+		} else {
+			comparison.newResults = append(comparison.newResults, result)
+		}
+	}
+
+	for _, result := range baselineResults {
+		if result == nil {
+			continue
+		}
+		key := baselineKey(result)
+		if matches := baselineKeyToResults[key]; len(matches) > 0 && matches[0] == result {
+			baselineKeyToResults[key] = matches[1:]
+			comparison.absentResults = append(comparison.absentResults, result)
+		}
+	}
+
+	return comparison
+}
+
+// Identify a result independent of the commit it was found on. Partial fingerprints are used when reported; otherwise
+// the rule, file, and whitespace-normalized snippet (or message, when no snippet is reported) are used. Line numbers
+// are never used, as unrelated changes above a finding move it.
+func baselineKey(result *sarif.Result) string {
+	if len(result.PartialFingerprints) > 0 {
+		var fingerprints []string
+		for key, value := range result.PartialFingerprints {
+			fingerprints = append(fingerprints, fmt.Sprintf("%s=%s", key, value))
+		}
+		sort.Strings(fingerprints)
+		return fmt.Sprintf("%s|fingerprints|%s", result.RuleID, strings.Join(fingerprints, ","))
+	}
+
+	var filepath, snippet string
+	if len(result.Locations) > 0 {
+		filepath = result.Locations[0].Filepath
+		snippet = result.Locations[0].Snippet
+	}
+	if strings.TrimSpace(snippet) == "" {
+		return fmt.Sprintf("%s|%s|message|%s", result.RuleID, filepath, normalizeWhitespace(result.Message))
+	}
+	return fmt.Sprintf("%s|%s|snippet|%s", result.RuleID, filepath, normalizeWhitespace(snippet))
+}
+
+func normalizeWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
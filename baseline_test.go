@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"less-advanced-security/sarif"
+)
+
+func TestBaselineKey(t *testing.T) {
+	five, ten := 5, 10
+
+	withSnippet := sarif.Result{RuleID: "no-print", Message: "no print", Locations: []sarif.ResultLocation{{Filepath: "src/main.py", StartLine: &five, Snippet: "  print('hi')\n"}}}
+	movedWithSnippet := sarif.Result{RuleID: "no-print", Message: "no print", Locations: []sarif.ResultLocation{{Filepath: "src/main.py", StartLine: &ten, Snippet: "print('hi')"}}}
+	otherFileWithSnippet := sarif.Result{RuleID: "no-print", Message: "no print", Locations: []sarif.ResultLocation{{Filepath: "src/other.py", StartLine: &five, Snippet: "print('hi')"}}}
+	withFingerprints := sarif.Result{RuleID: "no-print", PartialFingerprints: map[string]string{"primaryLocationLineHash": "abc", "other": "def"}, Locations: []sarif.ResultLocation{{Filepath: "src/main.py", StartLine: &five}}}
+	movedWithFingerprints := sarif.Result{RuleID: "no-print", PartialFingerprints: map[string]string{"other": "def", "primaryLocationLineHash": "abc"}, Locations: []sarif.ResultLocation{{Filepath: "src/renamed.py", StartLine: &ten}}}
+	withoutSnippet := sarif.Result{RuleID: "no-print", Message: "no  print", Locations: []sarif.ResultLocation{{Filepath: "src/main.py", StartLine: &five}}}
+	movedWithoutSnippet := sarif.Result{RuleID: "no-print", Message: "no print", Locations: []sarif.ResultLocation{{Filepath: "src/main.py", StartLine: &ten}}}
+
+	tests := []struct {
+		name  string
+		a, b  sarif.Result
+		match bool
+	}{
+		{"snippet moved lines", withSnippet, movedWithSnippet, true},
+		{"snippet in other file", withSnippet, otherFileWithSnippet, false},
+		{"fingerprints moved files", withFingerprints, movedWithFingerprints, true},
+		{"fingerprints and snippet", withFingerprints, withSnippet, false},
+		{"message moved lines", withoutSnippet, movedWithoutSnippet, true},
+		{"message and snippet", withoutSnippet, withSnippet, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baselineKey(&tt.a) == baselineKey(&tt.b); got != tt.match {
+				t.Errorf("expected match to be %t for keys %q and %q", tt.match, baselineKey(&tt.a), baselineKey(&tt.b))
+			}
+		})
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	printResult := sarif.Result{RuleID: "no-print", Message: "no print", Locations: []sarif.ResultLocation{{Filepath: "src/main.py"}}}
+	printResultAgain := printResult
+	evalResult := sarif.Result{RuleID: "no-eval", Message: "no eval", Locations: []sarif.ResultLocation{{Filepath: "src/main.py"}}}
+	execResult := sarif.Result{RuleID: "no-exec", Message: "no exec", Locations: []sarif.ResultLocation{{Filepath: "src/main.py"}}}
+
+	tests := []struct {
+		name                        string
+		results, baselineResults    []*sarif.Result
+		newCount, unchanged, absent int
+	}{
+		{"no baseline", []*sarif.Result{&printResult, &evalResult}, nil, 2, 0, 0},
+		{"all unchanged", []*sarif.Result{&printResult, &evalResult}, []*sarif.Result{&evalResult, &printResult}, 0, 2, 0},
+		{"one new one fixed", []*sarif.Result{&printResult, &evalResult}, []*sarif.Result{&printResult, &execResult}, 1, 1, 1},
+		{"duplicates beyond the baseline are new", []*sarif.Result{&printResult, &printResultAgain}, []*sarif.Result{&printResult}, 1, 1, 0},
+		{"duplicates fixed", []*sarif.Result{&printResult}, []*sarif.Result{&printResult, &printResultAgain}, 0, 1, 1},
+		{"everything fixed", []*sarif.Result{}, []*sarif.Result{&printResult, &evalResult, nil}, 0, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareToBaseline(tt.results, tt.baselineResults)
+			if len(got.newResults) != tt.newCount || len(got.unchangedResults) != tt.unchanged || len(got.absentResults) != tt.absent {
+				t.Errorf("expected %d new, %d unchanged, and %d absent but got %d, %d, and %d",
+					tt.newCount, tt.unchanged, tt.absent, len(got.newResults), len(got.unchangedResults), len(got.absentResults))
+			}
+		})
+	}
+}
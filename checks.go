@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"less-advanced-security/sarif"
+	"strings"
+)
+
+// A check is a single GitHub check run to be posted, along with the results which will become its annotations.
+type check struct {
+	name    string
+	tools   []*sarif.Tool
+	results []*sarif.Result
+}
+
+// Group parsed runs into checks. When mergeRuns is set, every run is posted as one check; otherwise runs are grouped
+// by tool driver (so results from the same tool across several runs or files share a check) and each tool is posted
+// as its own check named from its driver. A non-empty checkNameOverride names the merged check, or prefixes the name
+// of each per-tool check when there is more than one tool.
+func runsToChecks(runs []*sarif.Run, mergeRuns bool, checkNameOverride string) []*check {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	if mergeRuns {
+		merged := &check{name: checkNameOverride}
+		var toolNames []string
+		for _, run := range runs {
+			toolNames = append(toolNames, run.Tool.Name)
+			merged.tools = append(merged.tools, run.Tool)
+			merged.results = append(merged.results, run.Results...)
+		}
+		if merged.name == "" {
+			merged.name = strings.Join(uniqueStrings(toolNames), ", ")
+		}
+		return []*check{merged}
+	}
+
+	checks := []*check{}
+	toolToCheck := make(map[string]*check)
+	for _, run := range runs {
+		if existing, found := toolToCheck[run.Tool.Name]; found {
+			existing.tools = append(existing.tools, run.Tool)
+			existing.results = append(existing.results, run.Results...)
+			continue
+		}
+		toolCheck := &check{name: run.Tool.Name, tools: []*sarif.Tool{run.Tool}, results: append([]*sarif.Result{}, run.Results...)}
+		toolToCheck[run.Tool.Name] = toolCheck
+		checks = append(checks, toolCheck)
+	}
+
+	if checkNameOverride != "" {
+		for _, toolCheck := range checks {
+			if len(checks) > 1 {
+				toolCheck.name = fmt.Sprintf("%s (%s)", checkNameOverride, toolCheck.name)
+			} else {
+				toolCheck.name = checkNameOverride
+			}
+		}
+	}
+	return checks
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	unique := []string{}
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
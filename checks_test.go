@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"less-advanced-security/sarif"
+)
+
+func TestRunsToChecks(t *testing.T) {
+	semgrepResult := sarif.Result{RuleID: "no-print"}
+	brakemanResult := sarif.Result{RuleID: "BRAKE0109"}
+	codeqlResult := sarif.Result{RuleID: "js/xss"}
+
+	semgrepRun := sarif.Run{Tool: &sarif.Tool{Name: "semgrep"}, Results: []*sarif.Result{&semgrepResult}}
+	brakemanRun := sarif.Run{Tool: &sarif.Tool{Name: "Brakeman"}, Results: []*sarif.Result{&brakemanResult}}
+	codeqlRun := sarif.Run{Tool: &sarif.Tool{Name: "CodeQL"}, Results: []*sarif.Result{&codeqlResult}}
+	emptyCodeqlRun := sarif.Run{Tool: &sarif.Tool{Name: "CodeQL"}, Results: []*sarif.Result{}}
+
+	tests := []struct {
+		name              string
+		runs              []*sarif.Run
+		mergeRuns         bool
+		checkNameOverride string
+		expectedNames     []string
+		expectedCounts    []int
+	}{
+		{"no runs", []*sarif.Run{}, false, "", []string{}, []int{}},
+		{"no runs merged", []*sarif.Run{}, true, "", []string{}, []int{}},
+		{"one run", []*sarif.Run{&semgrepRun}, false, "", []string{"semgrep"}, []int{1}},
+		{"one run with override", []*sarif.Run{&semgrepRun}, false, "lint", []string{"lint"}, []int{1}},
+		{"two runs", []*sarif.Run{&semgrepRun, &brakemanRun}, false, "", []string{"semgrep", "Brakeman"}, []int{1, 1}},
+		{"two runs with override", []*sarif.Run{&semgrepRun, &brakemanRun}, false, "scan", []string{"scan (semgrep)", "scan (Brakeman)"}, []int{1, 1}},
+		{"two runs from the same driver", []*sarif.Run{&codeqlRun, &emptyCodeqlRun}, false, "", []string{"CodeQL"}, []int{1}},
+		{"three runs from two drivers", []*sarif.Run{&codeqlRun, &semgrepRun, &codeqlRun}, false, "", []string{"CodeQL", "semgrep"}, []int{2, 1}},
+		{"two runs from the same driver with override", []*sarif.Run{&codeqlRun, &codeqlRun}, false, "scan", []string{"scan"}, []int{2}},
+		{"two runs merged", []*sarif.Run{&semgrepRun, &brakemanRun}, true, "", []string{"semgrep, Brakeman"}, []int{2}},
+		{"two runs from the same driver merged", []*sarif.Run{&codeqlRun, &emptyCodeqlRun}, true, "", []string{"CodeQL"}, []int{1}},
+		{"two runs merged with override", []*sarif.Run{&semgrepRun, &brakemanRun}, true, "scan", []string{"scan"}, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runsToChecks(tt.runs, tt.mergeRuns, tt.checkNameOverride)
+
+			if len(got) != len(tt.expectedNames) {
+				t.Fatalf("expected %d checks but got %d", len(tt.expectedNames), len(got))
+			}
+			for i, check := range got {
+				if check.name != tt.expectedNames[i] {
+					t.Errorf("expected check %d to be named %q but it was %q", i, tt.expectedNames[i], check.name)
+				}
+				if len(check.results) != tt.expectedCounts[i] {
+					t.Errorf("expected check %d to have %d results but it had %d", i, tt.expectedCounts[i], len(check.results))
+				}
+			}
+		})
+	}
+}
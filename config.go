@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"less-advanced-security/github"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Where the repository's configuration file is read from.
+type configSource string
+
+const (
+	// the GitHub API, at the scanned commit (requires read access to the repository's contents)
+	githubConfigSource configSource = "github"
+	// the local checkout at --source_root
+	localConfigSource configSource = "local"
+)
+
+func parseConfigSource(source string) (configSource, error) {
+	switch configSource(source) {
+	case githubConfigSource, localConfigSource:
+		return configSource(source), nil
+	}
+	return "", errors.Errorf("invalid config source %q (must be one of github or local)", source)
+}
+
+// Configuration kept in the repository (e.g., .less-advanced-security.yml), so that repository owners can tune how
+// their findings are reported. Each value sets the flag of the same name, unless that flag is set explicitly.
+type repositoryConfig struct {
+	CheckName string `yaml:"check_name"`
+	// paths (or globs) whose findings are never reported
+	Ignore   []string `yaml:"ignore"`
+	Severity struct {
+		SecuritySeverityError   *float64          `yaml:"security_severity_error"`
+		SecuritySeverityWarning *float64          `yaml:"security_severity_warning"`
+		Rules                   map[string]string `yaml:"rules"`
+		Tools                   map[string]string `yaml:"tools"`
+		Default                 string            `yaml:"default"`
+	} `yaml:"severity"`
+	Conclusion struct {
+		FailOn      string `yaml:"fail_on"`
+		MaxErrors   *int   `yaml:"max_errors"`
+		MaxWarnings *int   `yaml:"max_warnings"`
+		MaxNotes    *int   `yaml:"max_notes"`
+		NoFindings  string `yaml:"no_findings"`
+	} `yaml:"conclusion"`
+	Filter struct {
+		Enabled          *bool  `yaml:"enabled"`
+		Granularity      string `yaml:"granularity"`
+		AddedLineContext *int   `yaml:"added_line_context"`
+		UnpatchedFiles   string `yaml:"unpatched_files"`
+	} `yaml:"filter"`
+}
+
+// A value for a flag, taken from the repository's configuration.
+type flagValue struct {
+	name, value string
+}
+
+// Parse and validate a configuration file, returning every problem found (rather than only the first) so they can all
+// be reported at once.
+func parseRepositoryConfig(content []byte) (*repositoryConfig, []string) {
+	config := &repositoryConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, []string{err.Error()}
+	}
+
+	var problems []string
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, pattern := range config.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			check(errors.Errorf("invalid ignore pattern %q", pattern))
+		}
+	}
+	// levels are case-insensitive, as they are for --default_level
+	if config.Severity.Default != "" && !isLevel(strings.ToLower(config.Severity.Default)) {
+		check(errors.Errorf("invalid default level %q (must be one of error, warning, note, or none)", config.Severity.Default))
+	}
+	_, err := parseLevelOverrides(levelOverrides(config.Severity.Rules))
+	check(errors.Wrap(err, "invalid rule level"))
+	_, err = parseLevelOverrides(levelOverrides(config.Severity.Tools))
+	check(errors.Wrap(err, "invalid tool level"))
+	if config.Conclusion.FailOn != "" {
+		_, err := github.ParseFailureLevel(config.Conclusion.FailOn)
+		check(err)
+	}
+	for name, maxCount := range map[string]*int{"max_errors": config.Conclusion.MaxErrors, "max_warnings": config.Conclusion.MaxWarnings, "max_notes": config.Conclusion.MaxNotes} {
+		if maxCount != nil && *maxCount < 0 {
+			check(errors.Errorf("%s must not be negative", name))
+		}
+	}
+	if config.Conclusion.NoFindings != "" {
+		_, err := github.ParseNoFindingsConclusion(config.Conclusion.NoFindings)
+		check(err)
+	}
+	if config.Filter.Granularity != "" {
+		_, err := github.ParseFilterGranularity(config.Filter.Granularity)
+		check(err)
+	}
+	if config.Filter.AddedLineContext != nil && *config.Filter.AddedLineContext < 0 {
+		check(errors.New("added_line_context must not be negative"))
+	}
+	if config.Filter.UnpatchedFiles != "" {
+		_, err := github.ParseUnpatchedFilePolicy(config.Filter.UnpatchedFiles)
+		check(err)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, problems
+	}
+	return config, nil
+}
+
+// The flag values set by the configuration.
+func (config *repositoryConfig) flagValues() []flagValue {
+	var values []flagValue
+	addString := func(name string, value string) {
+		if value != "" {
+			values = append(values, flagValue{name, value})
+		}
+	}
+	addInt := func(name string, value *int) {
+		if value != nil {
+			values = append(values, flagValue{name, strconv.Itoa(*value)})
+		}
+	}
+	addFloat := func(name string, value *float64) {
+		if value != nil {
+			values = append(values, flagValue{name, strconv.FormatFloat(*value, 'f', -1, 64)})
+		}
+	}
+
+	addString("check_name", config.CheckName)
+	for _, pattern := range config.Ignore {
+		addString("ignore_path", pattern)
+	}
+	addFloat("security_severity_error", config.Severity.SecuritySeverityError)
+	addFloat("security_severity_warning", config.Severity.SecuritySeverityWarning)
+	for _, override := range levelOverrides(config.Severity.Rules) {
+		addString("rule_level", override)
+	}
+	for _, override := range levelOverrides(config.Severity.Tools) {
+		addString("tool_level", override)
+	}
+	addString("default_level", config.Severity.Default)
+	addString("fail_on", config.Conclusion.FailOn)
+	addInt("max_errors", config.Conclusion.MaxErrors)
+	addInt("max_warnings", config.Conclusion.MaxWarnings)
+	addInt("max_notes", config.Conclusion.MaxNotes)
+	addString("no_findings_conclusion", config.Conclusion.NoFindings)
+	if config.Filter.Enabled != nil {
+		addString("filter_annotations", strconv.FormatBool(*config.Filter.Enabled))
+	}
+	addString("filter_granularity", config.Filter.Granularity)
+	addInt("added_line_context", config.Filter.AddedLineContext)
+	addString("unpatched_files", config.Filter.UnpatchedFiles)
+	return values
+}
+
+// Set flags from the configuration, leaving flags which were set explicitly (on the command line) unchanged.
+func (config *repositoryConfig) apply(flags *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for _, value := range config.flagValues() {
+		if explicit[value.name] {
+			continue
+		}
+		if err := flags.Set(value.name, value.value); err != nil {
+			return errors.Wrapf(err, "failed to set %s from the configuration", value.name)
+		}
+	}
+	return nil
+}
+
+// Read the configuration file from the scanned commit, or from the local checkout. A missing file is not an error.
+func readRepositoryConfig(source configSource, configPath string, sourceRoot string, clientConfiguration github.ClientConfiguration, owner string, repo string, sha string) ([]byte, error) {
+	if source == localConfigSource {
+		content, err := os.ReadFile(filepath.Join(sourceRoot, filepath.FromSlash(configPath)))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return content, errors.Wrapf(err, "failed to read %q", configPath)
+	}
+	return github.ReadRepositoryFile(clientConfiguration, owner, repo, sha, configPath)
+}
+
+// Convert a map of names to levels into sorted overrides of the form name=level.
+func levelOverrides(nameToLevel map[string]string) []string {
+	var overrides []string
+	for name, level := range nameToLevel {
+		overrides = append(overrides, fmt.Sprintf("%s=%s", name, level))
+	}
+	sort.Strings(overrides)
+	return overrides
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRepositoryConfig(t *testing.T) {
+	config, problems := parseRepositoryConfig([]byte(`
+check_name: Semgrep
+ignore: [vendor/, "*.min.js"]
+severity:
+  security_severity_error: 9
+  rules:
+    py/sql-injection: error
+    no-print: note
+  default: Note
+conclusion:
+  fail_on: warning
+  max_notes: 10
+filter:
+  enabled: false
+  granularity: added
+`))
+	if len(problems) > 0 {
+		t.Fatalf("expected no problems but got %v", problems)
+	}
+
+	expected := []flagValue{
+		{"check_name", "Semgrep"},
+		{"ignore_path", "vendor/"},
+		{"ignore_path", "*.min.js"},
+		{"security_severity_error", "9"},
+		{"rule_level", "no-print=note"},
+		{"rule_level", "py/sql-injection=error"},
+		{"default_level", "Note"},
+		{"fail_on", "warning"},
+		{"max_notes", "10"},
+		{"filter_annotations", "false"},
+		{"filter_granularity", "added"},
+	}
+	if got := config.flagValues(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected flag values %v but got %v", expected, got)
+	}
+}
+
+func TestParseRepositoryConfigEmpty(t *testing.T) {
+	config, problems := parseRepositoryConfig([]byte(""))
+	if len(problems) > 0 || config == nil || len(config.flagValues()) != 0 {
+		t.Errorf("expected an empty configuration but got %v (%v)", config, problems)
+	}
+}
+
+func TestParseRepositoryConfigProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		problems []string
+	}{
+		{"unknown field", "fail_on: warning\n", []string{"field fail_on not found"}},
+		{"malformed yaml", "check_name: [\n", []string{"yaml:"}},
+		{
+			"invalid values",
+			"ignore: [\"[\"]\nseverity:\n  rules: {sqli: critical}\nconclusion:\n  fail_on: sometimes\n  max_errors: -1\nfilter:\n  granularity: line\n",
+			[]string{"invalid failure level \"sometimes\"", "invalid filter granularity \"line\"", "invalid ignore pattern \"[\"", "invalid rule level", "max_errors must not be negative"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, problems := parseRepositoryConfig([]byte(tt.content))
+			if config != nil || len(problems) != len(tt.problems) {
+				t.Fatalf("expected %d problems but got %v", len(tt.problems), problems)
+			}
+			for i, problem := range tt.problems {
+				if !strings.Contains(problems[i], problem) {
+					t.Errorf("expected problem %q to contain %q", problems[i], problem)
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryConfigApply(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	checkName := flags.String("check_name", "", "")
+	failOn := flags.String("fail_on", "error", "")
+	var ruleLevels stringListFlag
+	flags.Var(&ruleLevels, "rule_level", "")
+	if err := flags.Parse([]string{"--fail_on=never"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config, problems := parseRepositoryConfig([]byte("check_name: Semgrep\nseverity:\n  rules: {a: error, b: note}\nconclusion:\n  fail_on: warning\n"))
+	if len(problems) > 0 {
+		t.Fatalf("expected no problems but got %v", problems)
+	}
+	if err := config.apply(flags); err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	if *checkName != "Semgrep" {
+		t.Errorf("expected the check name from the configuration but got %q", *checkName)
+	}
+	if *failOn != "never" {
+		t.Errorf("expected the explicit flag to override the configuration but got %q", *failOn)
+	}
+	if !reflect.DeepEqual([]string(ruleLevels), []string{"a=error", "b=note"}) {
+		t.Errorf("expected rule levels from the configuration but got %v", ruleLevels)
+	}
+}
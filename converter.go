@@ -12,22 +12,48 @@ type AnnotationCount struct {
 	count      int
 }
 
-func resultsToAnnotations(results []*sarif.Result) ([]*github.Annotation, error) {
+// Determines which location(s) of a result are annotated.
+type locationPolicy string
+
+const (
+	// annotate the first location of each result
+	primaryLocationPolicy locationPolicy = "primary"
+	// annotate every location of each result
+	allLocationsPolicy locationPolicy = "all"
+	// annotate results with exactly one location, reporting all others in the check summary
+	summaryLocationPolicy locationPolicy = "summary"
+)
+
+func parseLocationPolicy(policy string) (locationPolicy, error) {
+	switch locationPolicy(policy) {
+	case primaryLocationPolicy, allLocationsPolicy, summaryLocationPolicy:
+		return locationPolicy(policy), nil
+	}
+	return "", errors.Errorf("invalid location policy %q (must be one of primary, all, or summary)", policy)
+}
+
+// Convert results to annotations, deduplicating identical annotations. Results which cannot be annotated (e.g., they
+// have no location) are returned as unannotated findings rather than aborting the conversion.
+func resultsToAnnotations(results []*sarif.Result, policy locationPolicy) ([]*github.Annotation, []*github.UnannotatedFinding) {
 	annotationHashToCount := make(map[[16]byte]*AnnotationCount)
+	var unannotatedFindings []*github.UnannotatedFinding
 	for _, result := range results {
 		if result == nil {
 			continue
 		}
-		annotation, err := resultToAnnotation(*result)
+		resultAnnotations, err := resultToAnnotations(*result, policy)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to normalize result")
+			unannotatedFindings = append(unannotatedFindings, resultToUnannotatedFinding(*result, err.Error()))
+			continue
 		}
 
-		annotationHash := annotation.Hash()
-		if annotationHashToCount[annotationHash] != nil {
-			annotationHashToCount[annotationHash].count += 1
-		} else {
-			annotationHashToCount[annotationHash] = &AnnotationCount{annotation: annotation, count: 1}
+		for _, annotation := range resultAnnotations {
+			annotationHash := annotation.Hash()
+			if annotationHashToCount[annotationHash] != nil {
+				annotationHashToCount[annotationHash].count += 1
+			} else {
+				annotationHashToCount[annotationHash] = &AnnotationCount{annotation: annotation, count: 1}
+			}
 		}
 	}
 	annotations := []*github.Annotation{}
@@ -36,24 +62,209 @@ func resultsToAnnotations(results []*sarif.Result) ([]*github.Annotation, error)
 		annotations = append(annotations, annotationCount.annotation)
 	}
 
-	return annotations, nil
+	return annotations, unannotatedFindings
+}
+
+// Convert the results of a check to annotations and the details reported in its summary. Results which were also
+// found on the base commit (baselineResults) are marked unchanged, and baseline results which are no longer reported
+// are listed as fixed.
+func checkToAnnotations(check *check, baselineResults []*sarif.Result, policy locationPolicy) ([]*github.Annotation, github.CheckRunDetails) {
+	comparison := compareToBaseline(check.results, baselineResults)
+
+	annotations, unannotatedFindings := resultsToAnnotations(comparison.newResults, policy)
+	unchangedAnnotations, unchangedUnannotatedFindings := resultsToAnnotations(comparison.unchangedResults, policy)
+	for _, annotation := range unchangedAnnotations {
+		annotation.MarkUnchanged()
+	}
+	annotations = append(annotations, unchangedAnnotations...)
+	unannotatedFindings = append(unannotatedFindings, unchangedUnannotatedFindings...)
+
+	var fixedFindings []*github.UnannotatedFinding
+	for _, result := range comparison.absentResults {
+		fixedFindings = append(fixedFindings, resultToUnannotatedFinding(*result, ""))
+	}
+
+	details := toolsToDetails(check.tools)
+	details.UnannotatedFindings = unannotatedFindings
+	details.FixedFindings = fixedFindings
+	return annotations, details
+}
+
+// Describe the tools (and their rules) which reported a check's results, skipping duplicates across runs.
+func toolsToDetails(tools []*sarif.Tool) github.CheckRunDetails {
+	details := github.CheckRunDetails{}
+	seenTools := make(map[github.ToolDescription]bool)
+	seenRules := make(map[string]bool)
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		description := github.ToolDescription{Name: tool.Name}
+		if tool.Version != nil {
+			description.Version = *tool.Version
+		}
+		if !seenTools[description] {
+			seenTools[description] = true
+			details.Tools = append(details.Tools, description)
+		}
+
+		for _, rule := range tool.Rules {
+			if seenRules[rule.ID] {
+				continue
+			}
+			seenRules[rule.ID] = true
+			details.Rules = append(details.Rules, github.RuleDescription{
+				ID:          rule.ID,
+				Name:        rule.Name,
+				Description: rule.ShortDescription,
+				HelpURI:     rule.HelpURI,
+			})
+		}
+	}
+	return details
 }
 
-func resultToAnnotation(result sarif.Result) (*github.Annotation, error) {
-	if len(result.Locations) != 1 {
-		return nil, errors.Errorf("each result must have 1 location, not %d", len(result.Locations))
+func resultToAnnotations(result sarif.Result, policy locationPolicy) ([]*github.Annotation, error) {
+	if len(result.Locations) == 0 {
+		return nil, errors.New("result has no location")
+	}
+
+	locations := result.Locations
+	switch policy {
+	case summaryLocationPolicy:
+		if len(result.Locations) != 1 {
+			return nil, errors.Errorf("result has %d locations", len(result.Locations))
+		}
+	case allLocationsPolicy:
+		// every location is annotated
+	default:
+		locations = result.Locations[:1]
+	}
+
+	var annotations []*github.Annotation
+	for _, location := range locations {
+		annotation, err := locationToAnnotation(result, location)
+		if err != nil {
+			if policy == allLocationsPolicy && len(locations) > 1 {
+				// annotate the locations which are usable
+				continue
+			}
+			return nil, err
+		}
+		annotations = append(annotations, annotation)
+	}
+	if len(annotations) == 0 {
+		return nil, errors.New("no location of the result has a start line")
 	}
-	if result.Locations[0].StartLine == nil {
+
+	return annotations, nil
+}
+
+func locationToAnnotation(result sarif.Result, location sarif.ResultLocation) (*github.Annotation, error) {
+	if location.StartLine == nil {
 		return nil, errors.Errorf("each result must have a start line")
 	}
-	startLine := *result.Locations[0].StartLine
+	startLine := *location.StartLine
 
 	endLine := startLine
-	if result.Locations[0].EndLine != nil {
-		endLine = *result.Locations[0].EndLine
+	if location.EndLine != nil {
+		endLine = *location.EndLine
 	}
 
 	title := result.RuleID
 
-	return github.CreateAnnotation(result.Locations[0].Filepath, startLine, endLine, result.Level, title, result.Message)
+	annotation, err := github.CreateAnnotation(location.Filepath, startLine, endLine, result.Level, title, result.Message)
+	if err != nil {
+		return nil, err
+	}
+	if location.StartColumn != nil {
+		// sarif end columns are exclusive, while GitHub's are inclusive
+		endColumn := 0
+		if location.EndColumn != nil {
+			endColumn = *location.EndColumn - 1
+		}
+		annotation.SetColumns(*location.StartColumn, endColumn)
+	}
+
+	annotation.AddRelatedLocations(toAnnotationLocations(result.RelatedLocations)...)
+	for _, codeFlow := range result.CodeFlows {
+		steps := toAnnotationLocations(codeFlow.Steps)
+		if len(steps) > 0 {
+			annotation.AddCodeFlows(github.AnnotationCodeFlow{Message: codeFlow.Message, Steps: steps})
+		}
+	}
+
+	return annotation, nil
+}
+
+// Convert sarif locations to annotation locations, dropping any without a start line.
+func toAnnotationLocations(locations []sarif.ResultLocation) []github.AnnotationLocation {
+	var annotationLocations []github.AnnotationLocation
+	for _, location := range locations {
+		if location.StartLine == nil {
+			continue
+		}
+		endLine := *location.StartLine
+		if location.EndLine != nil {
+			endLine = *location.EndLine
+		}
+		annotationLocations = append(annotationLocations, github.AnnotationLocation{
+			Path:      location.Filepath,
+			StartLine: *location.StartLine,
+			EndLine:   endLine,
+			Message:   location.Message,
+		})
+	}
+	return annotationLocations
+}
+
+func resultToUnannotatedFinding(result sarif.Result, reason string) *github.UnannotatedFinding {
+	finding := &github.UnannotatedFinding{
+		Title:   result.RuleID,
+		Message: result.Message,
+		Level:   result.Level,
+		Reason:  reason,
+	}
+	if len(result.Locations) > 0 {
+		finding.Path = result.Locations[0].Filepath
+	}
+	return finding
+}
+
+// Convert the fixes of each result into suggestions, one per changed file.
+func resultsToSuggestions(results []*sarif.Result) []*github.Suggestion {
+	var suggestions []*github.Suggestion
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, fix := range result.Fixes {
+			for _, change := range fix.Changes {
+				suggestion := &github.Suggestion{
+					Path:        change.Filepath,
+					Title:       result.RuleID,
+					Message:     result.Message,
+					Description: fix.Description,
+				}
+				for _, replacement := range change.Replacements {
+					suggestion.Replacements = append(suggestion.Replacements, github.SuggestionReplacement{
+						StartLine:   replacement.StartLine,
+						StartColumn: intOrZero(replacement.StartColumn),
+						EndLine:     replacement.EndLine,
+						EndColumn:   intOrZero(replacement.EndColumn),
+						Text:        replacement.InsertedText,
+					})
+				}
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+	}
+	return suggestions
+}
+
+func intOrZero(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
 }
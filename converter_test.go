@@ -9,7 +9,7 @@ import (
 )
 
 func TestSarifToAnnotationConverter(t *testing.T) {
-	five, ten := 5, 10
+	five, ten, twenty := 5, 10, 20
 
 	sarifWithStartLine := sarif.Result{
 		Message: "this is a failure",
@@ -33,64 +33,162 @@ func TestSarifToAnnotationConverter(t *testing.T) {
 	// accuracy of annotation creation tested elsewhere
 	annotationWithStartAndEndLine, _ := github.CreateAnnotation("test/file", five, ten, "error", "fail-1-2-3", "this is a failure")
 
+	sarifWithTwoLocations := sarif.Result{
+		Message: "this is a failure",
+		RuleID:  "fail-1-2-3",
+		Raw:     "raw failure text",
+		Level:   "error",
+		Locations: []sarif.ResultLocation{
+			sarif.ResultLocation{Filepath: "test/file", StartLine: &five},
+			sarif.ResultLocation{Filepath: "test/other_file", StartLine: &twenty}},
+	}
+	// accuracy of annotation creation tested elsewhere
+	annotationOfSecondLocation, _ := github.CreateAnnotation("test/other_file", twenty, twenty, "error", "fail-1-2-3", "this is a failure")
+
+	sarifWithOneUsableLocation := sarif.Result{
+		Message: "this is a failure",
+		RuleID:  "fail-1-2-3",
+		Raw:     "raw failure text",
+		Level:   "error",
+		Locations: []sarif.ResultLocation{
+			sarif.ResultLocation{Filepath: "test/file"},
+			sarif.ResultLocation{Filepath: "test/other_file", StartLine: &twenty}},
+	}
+
 	tests := []struct {
-		name       string
-		result     sarif.Result
-		annotation *github.Annotation
-		errMessage string
+		name        string
+		result      sarif.Result
+		policy      locationPolicy
+		annotations []*github.Annotation
+		errMessage  string
 	}{
 		{
 			"no locations",
 			sarif.Result{Locations: []sarif.ResultLocation{}},
-			&github.Annotation{},
-			"each result must have 1 location, not 0",
+			primaryLocationPolicy,
+			nil,
+			"result has no location",
 		},
 		{
-			"two locations",
+			"no locations with all policy",
+			sarif.Result{Locations: []sarif.ResultLocation{}},
+			allLocationsPolicy,
+			nil,
+			"result has no location",
+		},
+		{
+			"two locations with summary policy",
 			sarif.Result{Locations: []sarif.ResultLocation{sarif.ResultLocation{}, sarif.ResultLocation{}}},
-			&github.Annotation{},
-			"each result must have 1 location, not 2",
+			summaryLocationPolicy,
+			nil,
+			"result has 2 locations",
 		},
 		{
 			"no start line",
 			sarif.Result{Locations: []sarif.ResultLocation{sarif.ResultLocation{Filepath: "test/file"}}},
-			&github.Annotation{},
+			primaryLocationPolicy,
+			nil,
 			"each result must have a start line",
 		},
+		{
+			"no start line in any location with all policy",
+			sarif.Result{Locations: []sarif.ResultLocation{sarif.ResultLocation{Filepath: "test/file"}, sarif.ResultLocation{Filepath: "test/file"}}},
+			allLocationsPolicy,
+			nil,
+			"no location of the result has a start line",
+		},
 		{
 			"start line only",
 			sarifWithStartLine,
-			annotationWithStartLine,
+			primaryLocationPolicy,
+			[]*github.Annotation{annotationWithStartLine},
+			"",
+		},
+		{
+			"start line only with summary policy",
+			sarifWithStartLine,
+			summaryLocationPolicy,
+			[]*github.Annotation{annotationWithStartLine},
 			"",
 		},
 		{
 			"start and end line",
 			sarifWithStartAndEndLine,
-			annotationWithStartAndEndLine,
+			primaryLocationPolicy,
+			[]*github.Annotation{annotationWithStartAndEndLine},
+			"",
+		},
+		{
+			"two locations with primary policy",
+			sarifWithTwoLocations,
+			primaryLocationPolicy,
+			[]*github.Annotation{annotationWithStartLine},
+			"",
+		},
+		{
+			"two locations with all policy",
+			sarifWithTwoLocations,
+			allLocationsPolicy,
+			[]*github.Annotation{annotationWithStartLine, annotationOfSecondLocation},
+			"",
+		},
+		{
+			"one usable location with all policy",
+			sarifWithOneUsableLocation,
+			allLocationsPolicy,
+			[]*github.Annotation{annotationOfSecondLocation},
 			"",
 		},
+		{
+			"one usable location with primary policy",
+			sarifWithOneUsableLocation,
+			primaryLocationPolicy,
+			nil,
+			"each result must have a start line",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := resultToAnnotation(tt.result)
+			got, err := resultToAnnotations(tt.result, tt.policy)
 			if tt.errMessage != "" {
 				if err == nil || err.Error() != tt.errMessage {
 					t.Errorf("Expected error %q but got %q.", tt.errMessage, err)
 				}
 			} else if err != nil {
 				t.Errorf("Got error %q but expected no error.", err)
-			} else if !reflect.DeepEqual(got, tt.annotation) {
-				t.Errorf("Expected annotation %s but got %s.", tt.annotation, got)
+			} else if !reflect.DeepEqual(got, tt.annotations) {
+				t.Errorf("Expected annotations %s but got %s.", tt.annotations, got)
 			}
 		})
 	}
 }
 
+func TestParseLocationPolicy(t *testing.T) {
+	for _, valid := range []string{"primary", "all", "summary"} {
+		if got, err := parseLocationPolicy(valid); err != nil || string(got) != valid {
+			t.Errorf("expected %q to parse but got %q (%v)", valid, got, err)
+		}
+	}
+	for _, invalid := range []string{"", "first", "ALL"} {
+		if _, err := parseLocationPolicy(invalid); err == nil {
+			t.Errorf("expected an error for %q but received none", invalid)
+		}
+	}
+}
+
 func TestSarifsToAnnotationsConverter(t *testing.T) {
 	five, six, ten := 5, 6, 10
 
 	sarifWithNoLocation := sarif.Result{Locations: []sarif.ResultLocation{}}
 
+	sarifWithInvalidLevel := sarif.Result{
+		Message: "this is a failure",
+		RuleID:  "fail-1-2-3",
+		Level:   "info",
+		Locations: []sarif.ResultLocation{
+			sarif.ResultLocation{Filepath: "test/file", StartLine: &five}},
+	}
+
 	sarifOriginal := sarif.Result{
 		Message: "this is a failure",
 		RuleID:  "fail-1-2-3",
@@ -101,7 +199,8 @@ func TestSarifsToAnnotationsConverter(t *testing.T) {
 	}
 	// accuracy of annotation creation tested elsewhere
 	annotationOriginal, _ := github.CreateAnnotation("test/file", five, five, "error", "fail-1-2-3", "this is a failure")
-	annotationOriginalReportedTwice, _ := github.CreateAnnotation("test/file", five, five, "error", "fail-1-2-3 (reported 2 times)", "this is a failure")
+	annotationOriginalReportedTwice, _ := github.CreateAnnotation("test/file", five, five, "error", "fail-1-2-3", "this is a failure")
+	annotationOriginalReportedTwice.MaybeAppendReportCount(2)
 
 	sarifAsWarning := sarif.Result{
 		Message: "this is a failure",
@@ -113,7 +212,8 @@ func TestSarifsToAnnotationsConverter(t *testing.T) {
 	}
 	// accuracy of annotation creation tested elsewhere
 	annotationAsWarning, _ := github.CreateAnnotation("test/file", five, five, "warning", "fail-1-2-3", "this is a failure")
-	annotationAsWarningReportedTwice, _ := github.CreateAnnotation("test/file", five, five, "warning", "fail-1-2-3 (reported 2 times)", "this is a failure")
+	annotationAsWarningReportedTwice, _ := github.CreateAnnotation("test/file", five, five, "warning", "fail-1-2-3", "this is a failure")
+	annotationAsWarningReportedTwice.MaybeAppendReportCount(2)
 
 	sarifNewId := sarif.Result{
 		Message: "this is a failure",
@@ -152,67 +252,147 @@ func TestSarifsToAnnotationsConverter(t *testing.T) {
 		name                string
 		results             []*sarif.Result
 		expectedAnnotations []*github.Annotation
-		errMessage          string
+		expectedUnannotated int
 	}{
 		{
 			"no locations",
 			[]*sarif.Result{&sarifWithNoLocation},
 			[]*github.Annotation{},
-			"failed to normalize result: each result must have 1 location, not 0",
+			1,
+		}, {
+			"no locations alongside a located result",
+			[]*sarif.Result{&sarifWithNoLocation, &sarifOriginal},
+			[]*github.Annotation{annotationOriginal},
+			1,
+		}, {
+			"invalid level",
+			[]*sarif.Result{&sarifWithInvalidLevel, &sarifOriginal},
+			[]*github.Annotation{annotationOriginal},
+			1,
 		}, {
 			"two results",
 			[]*sarif.Result{&sarifOriginal, &sarifAsWarning},
 			[]*github.Annotation{annotationOriginal, annotationAsWarning},
-			"",
+			0,
 		}, {
 			"two sets of duplicate results",
 			[]*sarif.Result{&sarifOriginal, &sarifAsWarning, &sarifOriginal, &sarifAsWarning},
 			[]*github.Annotation{annotationOriginalReportedTwice, annotationAsWarningReportedTwice},
-			"",
+			0,
 		}, {
 			"not duplicated due to start line",
 			[]*sarif.Result{&sarifOriginal, &sarifNewStartLine},
 			[]*github.Annotation{annotationOriginal, annotationNewStartLine},
-			"",
+			0,
 		}, {
 			"not duplicated due to end line",
 			[]*sarif.Result{&sarifOriginal, &sarifNewEndLine},
 			[]*github.Annotation{annotationOriginal, annotationNewEndLine},
-			"",
+			0,
 		}, {
 			"not duplicated due to id",
 			[]*sarif.Result{&sarifOriginal, &sarifNewId},
 			[]*github.Annotation{annotationOriginal, annotationNewId},
-			"",
+			0,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotAnnotations, err := resultsToAnnotations(tt.results)
-			if tt.errMessage != "" {
-				if err == nil || err.Error() != tt.errMessage {
-					t.Errorf("Expected error %q but got %q.", tt.errMessage, err)
-				}
-			} else if err != nil {
-				t.Errorf("Expected no error but got %q.", err)
-			} else {
-				for _, expectedAnnotation := range tt.expectedAnnotations {
-					found := false
-					for _, gotAnnotation := range gotAnnotations {
-						if reflect.DeepEqual(gotAnnotation, expectedAnnotation) {
-							found = true
-							break
-						}
-					}
-					if !found {
-						t.Errorf("Expected annotation %s but did not find it.", expectedAnnotation)
+			gotAnnotations, gotUnannotated := resultsToAnnotations(tt.results, primaryLocationPolicy)
+			if len(gotUnannotated) != tt.expectedUnannotated {
+				t.Errorf("expected %d unannotated findings but got %d", tt.expectedUnannotated, len(gotUnannotated))
+			}
+			for _, expectedAnnotation := range tt.expectedAnnotations {
+				found := false
+				for _, gotAnnotation := range gotAnnotations {
+					if reflect.DeepEqual(gotAnnotation, expectedAnnotation) {
+						found = true
+						break
 					}
 				}
-
-				if len(tt.expectedAnnotations) != len(gotAnnotations) {
-					t.Errorf("expected %d annotations but got %d", len(tt.expectedAnnotations), len(gotAnnotations))
+				if !found {
+					t.Errorf("Expected annotation %s but did not find it.", expectedAnnotation)
 				}
 			}
+
+			if len(tt.expectedAnnotations) != len(gotAnnotations) {
+				t.Errorf("expected %d annotations but got %d", len(tt.expectedAnnotations), len(gotAnnotations))
+			}
 		})
 	}
 }
+
+func TestResultsToSuggestions(t *testing.T) {
+	five, eleven := 5, 11
+
+	withFix := sarif.Result{
+		Message: "use a logger",
+		RuleID:  "no-print",
+		Fixes: []sarif.Fix{{
+			Description: "replace print",
+			Changes: []sarif.FileChange{
+				{Filepath: "src/main.py", Replacements: []sarif.Replacement{{StartLine: 4, EndLine: 4, StartColumn: &five, EndColumn: &eleven, InsertedText: "logger.info"}}},
+				{Filepath: "src/other.py", Replacements: []sarif.Replacement{{StartLine: 2, EndLine: 3}}},
+			},
+		}},
+	}
+	withoutFix := sarif.Result{Message: "no fix", RuleID: "no-fix"}
+
+	got := resultsToSuggestions([]*sarif.Result{&withFix, &withoutFix, nil})
+
+	expected := []*github.Suggestion{
+		{
+			Path:         "src/main.py",
+			Title:        "no-print",
+			Message:      "use a logger",
+			Description:  "replace print",
+			Replacements: []github.SuggestionReplacement{{StartLine: 4, StartColumn: 5, EndLine: 4, EndColumn: 11, Text: "logger.info"}},
+		},
+		{
+			Path:         "src/other.py",
+			Title:        "no-print",
+			Message:      "use a logger",
+			Description:  "replace print",
+			Replacements: []github.SuggestionReplacement{{StartLine: 2, EndLine: 3}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected suggestions %+v but got %+v", expected, got)
+	}
+}
+
+func TestToolsToDetails(t *testing.T) {
+	version := "1.2.3"
+	semgrep := sarif.Tool{Name: "semgrep", Version: &version, Rules: []sarif.Rule{{ID: "no-print", Name: "NoPrint", ShortDescription: "Avoid print", HelpURI: "https://example.com"}}}
+	brakeman := sarif.Tool{Name: "Brakeman", Rules: []sarif.Rule{{ID: "BRAKE0109"}, {ID: "no-print"}}}
+
+	got := toolsToDetails([]*sarif.Tool{&semgrep, &brakeman, &semgrep, nil})
+
+	expectedTools := []github.ToolDescription{{Name: "semgrep", Version: "1.2.3"}, {Name: "Brakeman"}}
+	if !reflect.DeepEqual(got.Tools, expectedTools) {
+		t.Errorf("expected tools %v but got %v", expectedTools, got.Tools)
+	}
+	expectedRules := []github.RuleDescription{
+		{ID: "no-print", Name: "NoPrint", Description: "Avoid print", HelpURI: "https://example.com"},
+		{ID: "BRAKE0109"},
+	}
+	if !reflect.DeepEqual(got.Rules, expectedRules) {
+		t.Errorf("expected rules %v but got %v", expectedRules, got.Rules)
+	}
+}
+
+func TestLocationToAnnotationColumns(t *testing.T) {
+	three, eight, twelve := 3, 8, 12
+	result := sarif.Result{RuleID: "rule", Level: "warning", Message: "message"}
+
+	annotation, err := locationToAnnotation(result, sarif.ResultLocation{Filepath: "src/main.py", StartLine: &three, StartColumn: &eight, EndColumn: &twelve})
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	expected, _ := github.CreateAnnotation("src/main.py", three, three, "warning", "rule", "message")
+	// sarif end columns are exclusive
+	expected.SetColumns(8, 11)
+	if annotation.Hash() != expected.Hash() {
+		t.Errorf("expected annotation %v but got %v", expected, annotation)
+	}
+}
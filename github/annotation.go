@@ -19,6 +19,25 @@ type Annotation struct {
 	fileName           string
 	startLine, endLine int
 	level              int
+	ruleID             string
+	relatedLocations   []AnnotationLocation
+	codeFlows          []AnnotationCodeFlow
+	// unchanged annotations were also found on the pull request's base commit, and do not affect the conclusion
+	unchanged bool
+}
+
+// A location related to an annotation (e.g., where tainted data was introduced), rendered in the annotation's raw
+// details.
+type AnnotationLocation struct {
+	Path               string
+	StartLine, EndLine int
+	Message            string
+}
+
+// An ordered path through the code (e.g., from a taint source to its sink), rendered in the annotation's raw details.
+type AnnotationCodeFlow struct {
+	Message string
+	Steps   []AnnotationLocation
 }
 
 func (a Annotation) String() string {
@@ -30,7 +49,12 @@ func (a Annotation) String() string {
 }
 
 func (a Annotation) Hash() [16]byte {
-	return md5.Sum([]byte(fmt.Sprintf("%d-%d-%s-%s-%d", a.startLine, a.endLine, a.fileName, *a.githubAnnotation.Title, a.level)))
+	key := fmt.Sprintf("%d-%d-%s-%s-%d", a.startLine, a.endLine, a.fileName, *a.githubAnnotation.Title, a.level)
+	if a.githubAnnotation.StartColumn != nil {
+		// findings on different expressions of the same line are distinct
+		key = fmt.Sprintf("%s-%d-%d", key, a.githubAnnotation.GetStartColumn(), a.githubAnnotation.GetEndColumn())
+	}
+	return md5.Sum([]byte(key))
 }
 
 func (a *Annotation) MaybeAppendReportCount(reportCount int) {
@@ -44,6 +68,44 @@ func (a *Annotation) MaybeAppendReportCount(reportCount int) {
 	}
 }
 
+// Highlight the exact columns of a single-line annotation (GitHub ignores columns on annotations spanning multiple
+// lines). Columns are one-indexed and inclusive; an endColumn of 0 leaves the end of the highlight unset.
+func (a *Annotation) SetColumns(startColumn int, endColumn int) {
+	if a.startLine != a.endLine || startColumn < 1 {
+		return
+	}
+	a.githubAnnotation.StartColumn = &startColumn
+	if endColumn >= startColumn {
+		a.githubAnnotation.EndColumn = &endColumn
+	}
+}
+
+// Mark the annotation as a finding which also exists on the pull request's base commit.
+func (a *Annotation) MarkUnchanged() {
+	if a.unchanged {
+		return
+	}
+	a.unchanged = true
+	newTitle := fmt.Sprintf("%s (pre-existing)", *a.githubAnnotation.Title)
+	a.githubAnnotation.Title = &newTitle
+}
+
+// Move the annotation to other lines (e.g., the matching lines of a later commit).
+func (a *Annotation) moveTo(path string, startLine int, endLine int) {
+	a.fileName, a.startLine, a.endLine = path, startLine, endLine
+	a.githubAnnotation.Path = &path
+	a.githubAnnotation.StartLine = &startLine
+	a.githubAnnotation.EndLine = &endLine
+}
+
+func (a *Annotation) AddRelatedLocations(locations ...AnnotationLocation) {
+	a.relatedLocations = append(a.relatedLocations, locations...)
+}
+
+func (a *Annotation) AddCodeFlows(codeFlows ...AnnotationCodeFlow) {
+	a.codeFlows = append(a.codeFlows, codeFlows...)
+}
+
 func checkRunAnnotationAsString(a *github.CheckRunAnnotation) string {
 	return fmt.Sprintf("{\"path\":%s,\"message\":%s,\"title\":%s,\"...\":\"...\"}", *a.Path, *a.Message, *a.Title)
 }
@@ -88,6 +150,7 @@ func CreateAnnotation(path string, startLine int, endLine int, level string, tit
 			AnnotationLevel: &normalizedLevelString,
 		},
 		level:     normalizedLevel,
+		ruleID:    title,
 		fileName:  path,
 		startLine: startLine,
 		endLine:   endLine,
@@ -96,8 +159,12 @@ func CreateAnnotation(path string, startLine int, endLine int, level string, tit
 
 func removeEndLines(annotations []*Annotation) {
 	for _, annotation := range annotations {
+		if annotation.endLine != annotation.startLine {
+			// columns only apply to annotations on a single line
+			annotation.githubAnnotation.StartColumn = nil
+			annotation.githubAnnotation.EndColumn = nil
+		}
 		annotation.endLine = annotation.startLine
 		annotation.githubAnnotation.EndLine = annotation.githubAnnotation.StartLine
-		annotation.githubAnnotation.EndColumn = nil
 	}
 }
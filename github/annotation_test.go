@@ -103,6 +103,20 @@ func TestAnnotationAppendReportCount(t *testing.T) {
 	}
 }
 
+func TestAnnotationMarkUnchanged(t *testing.T) {
+	annotation := Annotation{fileName: "test/file", startLine: 5, endLine: 5, level: failureLevel, githubAnnotation: &github.CheckRunAnnotation{Title: github.String("something bad")}}
+
+	annotation.MarkUnchanged()
+	annotation.MarkUnchanged()
+
+	if !annotation.unchanged {
+		t.Error("expected annotation to be marked unchanged")
+	}
+	if expectedTitle := "something bad (pre-existing)"; *annotation.githubAnnotation.Title != expectedTitle {
+		t.Errorf("expected annotation title to be %s but it was %s", expectedTitle, *annotation.githubAnnotation.Title)
+	}
+}
+
 func TestLevelStringToNormalizedLevelErrors(t *testing.T) {
 	tests := []string{"nil", "null", "info", "notice", "warn", "failure"}
 	for _, tt_in := range tests {
@@ -229,3 +243,32 @@ func TestRemoveEndLines(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnotationSetColumns(t *testing.T) {
+	single, _ := CreateAnnotation("src/main.py", 4, 4, "warning", "rule", "message")
+	single.SetColumns(9, 14)
+	if single.githubAnnotation.GetStartColumn() != 9 || single.githubAnnotation.GetEndColumn() != 14 {
+		t.Errorf("expected columns 9 to 14 but got %d to %d", single.githubAnnotation.GetStartColumn(), single.githubAnnotation.GetEndColumn())
+	}
+
+	startOnly, _ := CreateAnnotation("src/main.py", 4, 4, "warning", "rule", "message")
+	startOnly.SetColumns(9, 0)
+	if startOnly.githubAnnotation.GetStartColumn() != 9 || startOnly.githubAnnotation.EndColumn != nil {
+		t.Errorf("expected only a start column of 9 but got %v and %v", startOnly.githubAnnotation.StartColumn, startOnly.githubAnnotation.EndColumn)
+	}
+
+	multiLine, _ := CreateAnnotation("src/main.py", 4, 6, "warning", "rule", "message")
+	multiLine.SetColumns(9, 14)
+	if multiLine.githubAnnotation.StartColumn != nil || multiLine.githubAnnotation.EndColumn != nil {
+		t.Errorf("expected no columns on a multi-line annotation but got %v and %v", multiLine.githubAnnotation.StartColumn, multiLine.githubAnnotation.EndColumn)
+	}
+
+	if single.Hash() == startOnly.Hash() {
+		t.Error("expected annotations on different columns of a line to have different hashes")
+	}
+
+	removeEndLines([]*Annotation{single})
+	if single.githubAnnotation.GetStartColumn() != 9 || single.githubAnnotation.GetEndColumn() != 14 {
+		t.Errorf("expected removing end lines to keep the columns of a single-line annotation but got %v and %v", single.githubAnnotation.StartColumn, single.githubAnnotation.EndColumn)
+	}
+}
@@ -0,0 +1,262 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v47/github"
+	"github.com/pkg/errors"
+)
+
+// Determines what happens to check runs with the same name (and external ID) already on the commit, e.g., when a CI
+// job is re-run.
+type ExistingCheckRunPolicy string
+
+const (
+	// always create a new check run, leaving existing ones untouched
+	CreateCheckRunPolicy ExistingCheckRunPolicy = "create"
+	// create a new check run, marking existing ones as superseded
+	SupersedeCheckRunPolicy ExistingCheckRunPolicy = "supersede"
+	// update the latest existing check run in place when it has no annotations (annotations cannot be removed from a
+	// check run), otherwise supersede it
+	ReuseCheckRunPolicy ExistingCheckRunPolicy = "reuse"
+)
+
+// The external ID of check runs posted with a token when none is configured. Existing check runs cannot be told apart
+// by app when posting with a token (e.g., a workflow job's own check run is also created by GitHub Actions), so they
+// are matched by external ID instead.
+const tokenExternalID = "less-advanced-security"
+
+// A CommitChecker manages the check runs on a single commit, without loading a pull request (e.g., to start a check
+// run before a scan has produced any findings).
+type CommitChecker struct {
+	client               *github.Client
+	appID                int64
+	usesToken            bool
+	owner, repo, headSHA string
+}
+
+func CreateCommitChecker(configuration ClientConfiguration, owner string, repo string, headSHA string) (*CommitChecker, error) {
+	client, err := createClient(configuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create client")
+	}
+	return &CommitChecker{client: client, appID: configuration.AppID, usesToken: configuration.Token != "", owner: owner, repo: repo, headSHA: headSHA}, nil
+}
+
+// The external ID to set on check runs, and to match existing check runs against.
+func (checker *CommitChecker) externalID(configured string) string {
+	if configured == "" && checker.usesToken {
+		return tokenExternalID
+	}
+	return configured
+}
+
+// Create an in_progress check run, returning its ID.
+func (checker *CommitChecker) StartCheckRun(name string, externalID string) (int64, error) {
+	title := fmt.Sprintf("Scanning with %s", name)
+	summary := fmt.Sprintf("A scan for %s is in progress on commit %s.", name, checker.headSHA)
+	startedAt := github.Timestamp{Time: time.Now()}
+	options := github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    checker.headSHA,
+		ExternalID: optionalString(checker.externalID(externalID)),
+		Status:     github.String("in_progress"),
+		StartedAt:  &startedAt,
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+	}
+	checkRun, _, err := checker.client.Checks.CreateCheckRun(context.Background(), checker.owner, checker.repo, options)
+	if err != nil {
+		return 0, errors.Wrap(explainPermissionError(err, checksWritePermission), "failed to create check run")
+	}
+	return checkRun.GetID(), nil
+}
+
+// Complete a check run as failed because the scan itself failed, including the scanner's error output. When
+// checkRunID is 0, a new failed check run is created.
+func (checker *CommitChecker) FailCheckRun(checkRunID int64, name string, externalID string, errorOutput string) error {
+	title := fmt.Sprintf("%s failed", name)
+	summary := fmt.Sprintf("The scan for %s failed on commit %s before it could report findings.", name, checker.headSHA)
+	text := errorOutputAsMarkdown(errorOutput)
+	output := github.CheckRunOutput{Title: &title, Summary: &summary, Text: optionalString(text)}
+
+	completedAt := github.Timestamp{Time: time.Now()}
+	if checkRunID == 0 {
+		options := github.CreateCheckRunOptions{
+			Name:        name,
+			HeadSHA:     checker.headSHA,
+			ExternalID:  optionalString(checker.externalID(externalID)),
+			Conclusion:  github.String("failure"),
+			CompletedAt: &completedAt,
+			Output:      &output,
+		}
+		if _, _, err := checker.client.Checks.CreateCheckRun(context.Background(), checker.owner, checker.repo, options); err != nil {
+			return errors.Wrap(explainPermissionError(err, checksWritePermission), "failed to create check run")
+		}
+		return nil
+	}
+
+	options := github.UpdateCheckRunOptions{
+		Name:        name,
+		ExternalID:  optionalString(checker.externalID(externalID)),
+		Status:      github.String("completed"),
+		Conclusion:  github.String("failure"),
+		CompletedAt: &completedAt,
+		Output:      &output,
+	}
+	if _, _, err := checker.client.Checks.UpdateCheckRun(context.Background(), checker.owner, checker.repo, checkRunID, options); err != nil {
+		return errors.Wrapf(explainPermissionError(err, checksWritePermission), "failed to update check run %d", checkRunID)
+	}
+	return nil
+}
+
+// Complete a started check run which received no results (e.g., its tool reported under another name) as failed, as its
+// findings are unknown (a skipped or neutral conclusion would pass a required status check).
+func (checker *CommitChecker) FailUnreportedCheckRun(checkRunID int64, name string) error {
+	title := fmt.Sprintf("No results for %s", name)
+	summary := fmt.Sprintf("The scan on commit %s finished without reporting results for %s, so its findings are unknown.", checker.headSHA, name)
+	completedAt := github.Timestamp{Time: time.Now()}
+	options := github.UpdateCheckRunOptions{
+		Name:        name,
+		Status:      github.String("completed"),
+		Conclusion:  github.String("failure"),
+		CompletedAt: &completedAt,
+		Output:      &github.CheckRunOutput{Title: &title, Summary: &summary},
+	}
+	if _, _, err := checker.client.Checks.UpdateCheckRun(context.Background(), checker.owner, checker.repo, checkRunID, options); err != nil {
+		return errors.Wrapf(explainPermissionError(err, checksWritePermission), "failed to update check run %d", checkRunID)
+	}
+	return nil
+}
+
+// Render the end of the scanner's error output as a code block, keeping the check run text within GitHub's limit.
+func errorOutputAsMarkdown(errorOutput string) string {
+	errorOutput = strings.TrimSpace(errorOutput)
+	if errorOutput == "" {
+		return ""
+	}
+
+	const fence = "```"
+	const omissionNotice = "... (earlier output omitted)\n"
+	maxErrorOutputLength := maxOutputLength - len("### Error output\n\n") - 2*len(fence+"\n") - len(omissionNotice)
+	// escaping lengthens the output, so comes before truncation
+	errorOutput = strings.ReplaceAll(errorOutput, fence, "` ` `")
+	if len(errorOutput) > maxErrorOutputLength {
+		// keep the end, starting at a character boundary (GitHub rejects invalid UTF-8)
+		start := len(errorOutput) - maxErrorOutputLength
+		for start < len(errorOutput) && !utf8.RuneStart(errorOutput[start]) {
+			start++
+		}
+		errorOutput = omissionNotice + errorOutput[start:]
+	}
+	return fmt.Sprintf("### Error output\n\n%s\n%s\n%s", fence, errorOutput, fence)
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func ParseExistingCheckRunPolicy(policy string) (ExistingCheckRunPolicy, error) {
+	switch ExistingCheckRunPolicy(policy) {
+	case CreateCheckRunPolicy, SupersedeCheckRunPolicy, ReuseCheckRunPolicy:
+		return ExistingCheckRunPolicy(policy), nil
+	}
+	return "", errors.Errorf("invalid existing check run policy %q (must be one of create, supersede, or reuse)", policy)
+}
+
+// List the check runs on the head commit with the given name (and external ID, if set) created by this app, newest
+// first.
+func (checker *CommitChecker) existingCheckRuns(name string, externalID string) ([]*github.CheckRun, error) {
+	externalID = checker.externalID(externalID)
+	options := github.ListCheckRunsOptions{
+		CheckName: &name,
+		Filter:    github.String("all"),
+	}
+	if checker.appID > 0 && !checker.usesToken {
+		options.AppID = &checker.appID
+	}
+
+	var checkRuns []*github.CheckRun
+	for {
+		results, response, err := checker.client.Checks.ListCheckRunsForRef(context.Background(), checker.owner, checker.repo, checker.headSHA, &options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list check runs (page %d)", options.Page)
+		}
+		for _, checkRun := range results.CheckRuns {
+			if externalID != "" && checkRun.GetExternalID() != externalID {
+				continue
+			}
+			checkRuns = append(checkRuns, checkRun)
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+		options.Page = response.NextPage
+	}
+
+	sortCheckRunsNewestFirst(checkRuns)
+	return checkRuns, nil
+}
+
+func sortCheckRunsNewestFirst(checkRuns []*github.CheckRun) {
+	sort.SliceStable(checkRuns, func(i, j int) bool { return checkRuns[i].GetID() > checkRuns[j].GetID() })
+}
+
+// Choose an existing check run to update in place (if any), and the existing check runs to supersede. A non-zero
+// checkRunID (e.g., of a check run started before the scan) is always updated in place.
+func selectCheckRuns(policy ExistingCheckRunPolicy, existing []*github.CheckRun, checkRunID int64) (reuse *github.CheckRun, supersede []*github.CheckRun) {
+	if checkRunID != 0 {
+		reuse = &github.CheckRun{ID: &checkRunID}
+		if policy == CreateCheckRunPolicy {
+			return reuse, nil
+		}
+		supersede = []*github.CheckRun{}
+		for _, checkRun := range existing {
+			if checkRun.GetID() != checkRunID {
+				supersede = append(supersede, checkRun)
+			}
+		}
+		return reuse, supersede
+	}
+
+	switch policy {
+	case ReuseCheckRunPolicy:
+		if len(existing) > 0 && existing[0].GetOutput().GetAnnotationsCount() == 0 {
+			return existing[0], existing[1:]
+		}
+		return nil, existing
+	case SupersedeCheckRunPolicy:
+		return nil, existing
+	}
+	return nil, nil
+}
+
+// Mark a check run as replaced by a newer one (which may be nil if unknown).
+func (checker *CommitChecker) supersedeCheckRun(checkRun *github.CheckRun, replacement *github.CheckRun) error {
+	title := "Superseded"
+	summary := "This check run was superseded by a newer run."
+	if url := replacement.GetHTMLURL(); url != "" {
+		summary = "This check run was superseded by a [newer run](" + url + ")."
+	}
+
+	completedAt := github.Timestamp{Time: time.Now()}
+	options := github.UpdateCheckRunOptions{
+		Name:        checkRun.GetName(),
+		Status:      github.String("completed"),
+		Conclusion:  github.String("skipped"),
+		CompletedAt: &completedAt,
+		Output:      &github.CheckRunOutput{Title: &title, Summary: &summary},
+	}
+	if _, _, err := checker.client.Checks.UpdateCheckRun(context.Background(), checker.owner, checker.repo, checkRun.GetID(), options); err != nil {
+		return errors.Wrapf(explainPermissionError(err, checksWritePermission), "failed to supersede check run %d", checkRun.GetID())
+	}
+	return nil
+}
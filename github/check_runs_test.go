@@ -0,0 +1,157 @@
+package github
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v47/github"
+)
+
+func TestParseExistingCheckRunPolicy(t *testing.T) {
+	for _, valid := range []string{"create", "supersede", "reuse"} {
+		if got, err := ParseExistingCheckRunPolicy(valid); err != nil || string(got) != valid {
+			t.Errorf("expected %q to parse but got %q (%v)", valid, got, err)
+		}
+	}
+	for _, invalid := range []string{"", "update", "Reuse"} {
+		if _, err := ParseExistingCheckRunPolicy(invalid); err == nil {
+			t.Errorf("expected an error for %q but received none", invalid)
+		}
+	}
+}
+
+func TestSortCheckRunsNewestFirst(t *testing.T) {
+	checkRuns := []*github.CheckRun{{ID: github.Int64(2)}, {ID: github.Int64(7)}, {ID: github.Int64(4)}}
+	sortCheckRunsNewestFirst(checkRuns)
+	for i, expectedID := range []int64{7, 4, 2} {
+		if checkRuns[i].GetID() != expectedID {
+			t.Errorf("expected check run %d to have id %d but it had %d", i, expectedID, checkRuns[i].GetID())
+		}
+	}
+}
+
+func TestSelectCheckRuns(t *testing.T) {
+	withoutAnnotations := &github.CheckRun{ID: github.Int64(3), Output: &github.CheckRunOutput{AnnotationsCount: github.Int(0)}}
+	withAnnotations := &github.CheckRun{ID: github.Int64(2), Output: &github.CheckRunOutput{AnnotationsCount: github.Int(12)}}
+	withoutOutput := &github.CheckRun{ID: github.Int64(1)}
+
+	tests := []struct {
+		name              string
+		policy            ExistingCheckRunPolicy
+		existing          []*github.CheckRun
+		expectedReuse     *github.CheckRun
+		expectedSupersede []*github.CheckRun
+	}{
+		{"create ignores existing", CreateCheckRunPolicy, []*github.CheckRun{withoutAnnotations, withAnnotations}, nil, nil},
+		{"supersede all existing", SupersedeCheckRunPolicy, []*github.CheckRun{withoutAnnotations, withAnnotations}, nil, []*github.CheckRun{withoutAnnotations, withAnnotations}},
+		{"reuse latest without annotations", ReuseCheckRunPolicy, []*github.CheckRun{withoutAnnotations, withAnnotations}, withoutAnnotations, []*github.CheckRun{withAnnotations}},
+		{"reuse latest without output", ReuseCheckRunPolicy, []*github.CheckRun{withoutOutput}, withoutOutput, []*github.CheckRun{}},
+		{"supersede latest with annotations", ReuseCheckRunPolicy, []*github.CheckRun{withAnnotations, withoutOutput}, nil, []*github.CheckRun{withAnnotations, withoutOutput}},
+		{"reuse without existing", ReuseCheckRunPolicy, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reuse, supersede := selectCheckRuns(tt.policy, tt.existing, 0)
+			if reuse != tt.expectedReuse {
+				t.Errorf("expected to reuse %v but got %v", tt.expectedReuse, reuse)
+			}
+			if len(supersede) != len(tt.expectedSupersede) {
+				t.Fatalf("expected to supersede %d check runs but got %d", len(tt.expectedSupersede), len(supersede))
+			}
+			for i := range supersede {
+				if supersede[i] != tt.expectedSupersede[i] {
+					t.Errorf("expected to supersede %v but got %v", tt.expectedSupersede[i], supersede[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSelectCheckRunsWithStartedCheckRun(t *testing.T) {
+	started := &github.CheckRun{ID: github.Int64(5)}
+	older := &github.CheckRun{ID: github.Int64(2), Output: &github.CheckRunOutput{AnnotationsCount: github.Int(0)}}
+
+	reuse, supersede := selectCheckRuns(ReuseCheckRunPolicy, []*github.CheckRun{started, older}, 5)
+	if reuse.GetID() != 5 {
+		t.Errorf("expected to reuse the started check run but got %v", reuse)
+	}
+	if len(supersede) != 1 || supersede[0] != older {
+		t.Errorf("expected to supersede only the older check run but got %v", supersede)
+	}
+
+	reuse, supersede = selectCheckRuns(CreateCheckRunPolicy, nil, 5)
+	if reuse.GetID() != 5 || supersede != nil {
+		t.Errorf("expected to reuse the started check run and supersede nothing but got %v and %v", reuse, supersede)
+	}
+}
+
+func TestErrorOutputAsMarkdown(t *testing.T) {
+	if got := errorOutputAsMarkdown(" \n"); got != "" {
+		t.Errorf("expected no text for empty output but got %q", got)
+	}
+
+	expected := "### Error output\n\n```\npanic: boom\n```"
+	if got := errorOutputAsMarkdown("panic: boom\n"); got != expected {
+		t.Errorf("expected %q but got %q", expected, got)
+	}
+
+	long := strings.Repeat("x", maxOutputLength) + "the end"
+	got := errorOutputAsMarkdown(long)
+	if len(got) > maxOutputLength {
+		t.Errorf("expected at most %d characters but got %d", maxOutputLength, len(got))
+	}
+	if !strings.Contains(got, "... (earlier output omitted)") || !strings.HasSuffix(got, "the end\n```") {
+		t.Errorf("expected the end of the output to be kept but got %q", got[len(got)-50:])
+	}
+
+	for name, output := range map[string]string{
+		"backticks":  strings.Repeat("`", 2*maxOutputLength),
+		"multi-byte": strings.Repeat("エラー", maxOutputLength/3) + "x",
+	} {
+		got := errorOutputAsMarkdown(output)
+		if len(got) > maxOutputLength {
+			t.Errorf("expected at most %d characters for %s but got %d", maxOutputLength, name, len(got))
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("expected valid UTF-8 for %s", name)
+		}
+		if fences := strings.Count(got, "```"); fences != 2 {
+			t.Errorf("expected only the 2 fences of the code block for %s but got %d", name, fences)
+		}
+	}
+}
+
+func TestCommitCheckerExternalID(t *testing.T) {
+	app := &CommitChecker{appID: 12345}
+	token := &CommitChecker{appID: -1, usesToken: true}
+
+	if got := app.externalID(""); got != "" {
+		t.Errorf("expected no external id for an app but got %q", got)
+	}
+	if got := token.externalID(""); got != tokenExternalID {
+		t.Errorf("expected %q for a token but got %q", tokenExternalID, got)
+	}
+	if got := token.externalID("job-1"); got != "job-1" {
+		t.Errorf("expected the configured external id but got %q", got)
+	}
+}
+
+func TestExistingCheckRunsWithToken(t *testing.T) {
+	// the workflow job's own check run shares the name, but not the external id
+	body := `{"total_count": 2, "check_runs": [{"id": 1, "name": "semgrep"}, {"id": 2, "name": "semgrep", "external_id": "less-advanced-security"}]}`
+	response := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(bytes.NewReader([]byte(body)))}
+	transport := &fakeTransport{responses: []*http.Response{response}, errs: []error{nil}}
+	checker := &CommitChecker{client: github.NewClient(&http.Client{Transport: transport}), appID: -1, usesToken: true, owner: "o", repo: "r", headSHA: "abc123"}
+
+	checkRuns, err := checker.existingCheckRuns("semgrep", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkRuns) != 1 || checkRuns[0].GetID() != 2 {
+		t.Errorf("expected only check run 2 but got %v", checkRuns)
+	}
+}
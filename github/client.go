@@ -1,24 +1,206 @@
 package github
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v47/github"
 	"github.com/pkg/errors"
 )
 
+// The permission needed to create and update check runs.
+const checksWritePermission = "checks: write"
+
+// How to authenticate with GitHub: as a GitHub App installation (the default), or with a token when Token is set.
 type ClientConfiguration struct {
 	AppID, InstallationID int64
 	AppKeyPath            string
+	// the app's private key (PEM), used instead of AppKeyPath when set (e.g., a key read from the environment)
+	AppKey []byte
+	// a token (e.g., GitHub Actions' GITHUB_TOKEN or a personal access token), used instead of the app when set
+	Token string
+
+	// the API's base URL for GitHub Enterprise Server (e.g., https://github.example.com/api/v3/), defaults to github.com
+	APIURL string
+	// the upload API's base URL for GitHub Enterprise Server, defaults to /api/uploads/ on APIURL's host
+	UploadURL string
+	// a file of PEM certificates to trust in addition to the system's (e.g., an internal certificate authority)
+	CABundlePath string
+	// a proxy for every request, overriding the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables
+	ProxyURL string
+
+	// no request is retried after this time, when set
+	RetryDeadline time.Time
+	// counts the requests made by every client, when set
+	Stats *APIStats
 }
 
 func createClient(configuration ClientConfiguration) (*github.Client, error) {
-	itr, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, configuration.AppID, configuration.InstallationID, configuration.AppKeyPath)
+	transport, err := configuration.transport()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to configure the connection to GitHub")
+	}
+
+	if configuration.Token != "" {
+		return configuration.newGitHubClient(&http.Client{Transport: &tokenTransport{token: configuration.Token, transport: transport}})
+	}
+
+	var itr *ghinstallation.Transport
+	if len(configuration.AppKey) > 0 {
+		itr, err = ghinstallation.New(transport, configuration.AppID, configuration.InstallationID, configuration.AppKey)
+	} else {
+		itr, err = ghinstallation.NewKeyFromFile(transport, configuration.AppID, configuration.InstallationID, configuration.AppKeyPath)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to configure GitHub access")
 	}
 
-	client := github.NewClient(&http.Client{Transport: itr})
+	client, err := configuration.newGitHubClient(&http.Client{Transport: itr})
+	if err != nil {
+		return nil, err
+	}
+	// installation tokens are created through the same API
+	itr.BaseURL = strings.TrimSuffix(client.BaseURL.String(), "/")
 	return client, nil
 }
+
+// Find the installation of the configured GitHub App on a repository, authenticating as the app itself (so that one
+// app configuration can serve every repository and organization it is installed on).
+func DiscoverInstallationID(configuration ClientConfiguration, owner string, repo string) (int64, error) {
+	transport, err := configuration.transport()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to configure the connection to GitHub")
+	}
+
+	var atr *ghinstallation.AppsTransport
+	if len(configuration.AppKey) > 0 {
+		atr, err = ghinstallation.NewAppsTransport(transport, configuration.AppID, configuration.AppKey)
+	} else {
+		atr, err = ghinstallation.NewAppsTransportKeyFromFile(transport, configuration.AppID, configuration.AppKeyPath)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to configure GitHub access")
+	}
+	client, err := configuration.newGitHubClient(&http.Client{Transport: atr})
+	if err != nil {
+		return 0, err
+	}
+
+	installation, response, err := client.Apps.FindRepositoryInstallation(context.Background(), owner, repo)
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return 0, errors.Errorf("app %d is not installed on %s/%s (install it with access to the repository, or set --install_id)", configuration.AppID, owner, repo)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to find the installation of app %d on %s/%s", configuration.AppID, owner, repo)
+	}
+	return installation.GetID(), nil
+}
+
+// Whether the configuration targets GitHub Enterprise Server, rather than github.com (whose API URL is also set in
+// GitHub Actions, as GITHUB_API_URL).
+func (configuration ClientConfiguration) isEnterprise() bool {
+	apiURL := strings.TrimSuffix(configuration.APIURL, "/")
+	return apiURL != "" && apiURL != "https://api.github.com"
+}
+
+func (configuration ClientConfiguration) newGitHubClient(httpClient *http.Client) (*github.Client, error) {
+	if !configuration.isEnterprise() {
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := configuration.UploadURL
+	if uploadURL == "" {
+		// the upload API is served from the same host, under /api/uploads/ (which go-github adds) rather than /api/v3/
+		apiURL, err := url.Parse(configuration.APIURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid GitHub Enterprise Server URL %q", configuration.APIURL)
+		}
+		uploadURL = (&url.URL{Scheme: apiURL.Scheme, Host: apiURL.Host, Path: "/"}).String()
+	}
+	client, err := github.NewEnterpriseClient(configuration.APIURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid GitHub Enterprise Server URL %q", configuration.APIURL)
+	}
+	return client, nil
+}
+
+// The transport for requests to GitHub, which retries transient failures, trusts the configured certificates, and
+// uses the configured proxy.
+func (configuration ClientConfiguration) transport() (http.RoundTripper, error) {
+	transport, err := configuration.connectionTransport()
+	if err != nil {
+		return nil, err
+	}
+	return newRetryTransport(transport, configuration.RetryDeadline, configuration.Stats), nil
+}
+
+func (configuration ClientConfiguration) connectionTransport() (http.RoundTripper, error) {
+	if configuration.CABundlePath == "" && configuration.ProxyURL == "" {
+		return http.DefaultTransport, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if configuration.CABundlePath != "" {
+		bundle, err := os.ReadFile(configuration.CABundlePath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the CA bundle")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.Errorf("no PEM certificates were found in the CA bundle %q", configuration.CABundlePath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	if configuration.ProxyURL != "" {
+		proxyURL, err := url.Parse(configuration.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, errors.Errorf("invalid proxy URL %q", configuration.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+// Authenticates each request with a token.
+type tokenTransport struct {
+	token     string
+	transport http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer "+t.token)
+	return t.transport.RoundTrip(request)
+}
+
+// Explain an error from a request which needs a permission (in the form of a workflow's permissions, e.g.,
+// "checks: write"), when GitHub rejected the credential or denied it access.
+func explainPermissionError(err error, permission string) error {
+	var errorResponse *github.ErrorResponse
+	if err == nil || !errors.As(err, &errorResponse) || errorResponse.Response == nil {
+		return err
+	}
+
+	switch errorResponse.Response.StatusCode {
+	case http.StatusUnauthorized:
+		return errors.Wrap(err, "GitHub rejected the credential (check that the token has not expired, or that the app id, installation id, and key match)")
+	case http.StatusForbidden:
+		if permission == checksWritePermission {
+			// a personal access token is refused even with every scope
+			return errors.Wrapf(err, "the credential lacks the %q permission (only GitHub Apps can write check runs, so use a workflow's GITHUB_TOKEN, granting the permission under permissions and noting that the token is read-only for pull requests from forks, or a GitHub App, accepting the new permission on its installation; a personal access token will not work)", permission)
+		}
+		return errors.Wrapf(err, "the credential lacks the %q permission (grant it to a workflow's GITHUB_TOKEN under permissions, noting that the token is read-only for pull requests from forks, or to a GitHub App, accepting the new permission on its installation)", permission)
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v47/github"
+	"github.com/pkg/errors"
+)
+
+func TestTokenTransport(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &tokenTransport{token: "ghs_token", transport: http.DefaultTransport}}
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	response.Body.Close()
+
+	if authorization != "Bearer ghs_token" {
+		t.Errorf("expected the token to be sent but got %q", authorization)
+	}
+	if request.Header.Get("Authorization") != "" {
+		t.Errorf("expected the original request to be unchanged")
+	}
+}
+
+func TestExplainPermissionError(t *testing.T) {
+	responseError := func(status int) error {
+		return errors.Wrap(&github.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{}}, Message: "denied"}, "failed")
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		permission string
+		contains   string
+	}{
+		{"forbidden", responseError(http.StatusForbidden), "pull-requests: write", `lacks the "pull-requests: write" permission`},
+		{"forbidden check runs", responseError(http.StatusForbidden), checksWritePermission, "a personal access token will not work"},
+		{"unauthorized", responseError(http.StatusUnauthorized), checksWritePermission, "GitHub rejected the credential"},
+		{"server error", responseError(http.StatusBadGateway), checksWritePermission, "failed"},
+		{"other error", errors.New("connection reset"), checksWritePermission, "connection reset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := explainPermissionError(tt.err, tt.permission)
+			if !strings.Contains(got.Error(), tt.contains) {
+				t.Errorf("expected %q to contain %q", got, tt.contains)
+			}
+			if tt.name == "server error" && got != tt.err {
+				t.Errorf("expected the error to be unchanged")
+			}
+		})
+	}
+	if explainPermissionError(nil, checksWritePermission) != nil {
+		t.Errorf("expected no error to be unchanged")
+	}
+}
+
+func TestNewGitHubClient(t *testing.T) {
+	tests := []struct {
+		name                         string
+		configuration                ClientConfiguration
+		expectedBase, expectedUpload string
+	}{
+		{"github.com", ClientConfiguration{}, "https://api.github.com/", "https://uploads.github.com/"},
+		{"github.com from GitHub Actions", ClientConfiguration{APIURL: "https://api.github.com"}, "https://api.github.com/", "https://uploads.github.com/"},
+		{"enterprise server", ClientConfiguration{APIURL: "https://ghe.example.com"}, "https://ghe.example.com/api/v3/", "https://ghe.example.com/api/uploads/"},
+		{"enterprise server api path", ClientConfiguration{APIURL: "https://ghe.example.com/api/v3/"}, "https://ghe.example.com/api/v3/", "https://ghe.example.com/api/uploads/"},
+		{"enterprise server with upload url", ClientConfiguration{APIURL: "https://ghe.example.com/api/v3/", UploadURL: "https://uploads.ghe.example.com/"}, "https://ghe.example.com/api/v3/", "https://uploads.ghe.example.com/api/uploads/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.configuration.newGitHubClient(http.DefaultClient)
+			if err != nil {
+				t.Fatalf("expected no error but received %q", err)
+			}
+			if client.BaseURL.String() != tt.expectedBase || client.UploadURL.String() != tt.expectedUpload {
+				t.Errorf("expected %q and %q but got %q and %q", tt.expectedBase, tt.expectedUpload, client.BaseURL, client.UploadURL)
+			}
+		})
+	}
+}
+
+func TestCreateClientForEnterpriseServer(t *testing.T) {
+	var path, authorization string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, authorization = r.URL.Path, r.Header.Get("Authorization")
+		w.Write([]byte(`{"full_name": "eliblock/less-advanced-security"}`))
+	}))
+	defer server.Close()
+
+	bundlePath := filepath.Join(t.TempDir(), "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundlePath, bundle, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := createClient(ClientConfiguration{Token: "ghs_token", APIURL: server.URL, CABundlePath: bundlePath})
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	repository, _, err := client.Repositories.Get(context.Background(), "eliblock", "less-advanced-security")
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	if repository.GetFullName() != "eliblock/less-advanced-security" || path != "/api/v3/repos/eliblock/less-advanced-security" || authorization != "Bearer ghs_token" {
+		t.Errorf("unexpected request to %q (authorization %q)", path, authorization)
+	}
+}
+
+func TestClientConfigurationTransportErrors(t *testing.T) {
+	emptyBundlePath := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(emptyBundlePath, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, configuration := range []ClientConfiguration{
+		{CABundlePath: filepath.Join(t.TempDir(), "missing.pem")},
+		{CABundlePath: emptyBundlePath},
+		{ProxyURL: "not a url"},
+	} {
+		if _, err := configuration.transport(); err == nil {
+			t.Errorf("expected an error for %+v", configuration)
+		}
+	}
+
+	transport, err := ClientConfiguration{ProxyURL: "http://proxy.example.com:3128"}.connectionTransport()
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	request, _ := http.NewRequest(http.MethodGet, "https://api.github.com/", nil)
+	if proxy, _ := transport.(*http.Transport).Proxy(request); proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("expected the proxy to be used but got %v", proxy)
+	}
+}
+
+func TestDiscoverInstallationID(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/api/v3/repos/eliblock/less-advanced-security/installation":
+			w.Write([]byte(`{"id": 87654321}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	configuration := ClientConfiguration{AppID: 12345, AppKey: keyPEM, APIURL: server.URL}
+	id, err := DiscoverInstallationID(configuration, "eliblock", "less-advanced-security")
+	if err != nil || id != 87654321 {
+		t.Errorf("expected installation 87654321 but got %d (%v)", id, err)
+	}
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		t.Errorf("expected to authenticate as the app but got %q", authorization)
+	}
+
+	if _, err := DiscoverInstallationID(configuration, "eliblock", "other"); err == nil || !strings.Contains(err.Error(), "app 12345 is not installed on eliblock/other") {
+		t.Errorf("expected an error for a repository without the app but got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The lowest level of new findings which fails a check.
+type FailureLevel string
+
+const (
+	FailOnError   FailureLevel = "error"
+	FailOnWarning FailureLevel = "warning"
+	FailOnNote    FailureLevel = "note"
+	// never fail a check (e.g., while rolling out a new tool), unless a maximum count is exceeded
+	FailOnNever FailureLevel = "never"
+)
+
+func ParseFailureLevel(level string) (FailureLevel, error) {
+	switch FailureLevel(level) {
+	case FailOnError, FailOnWarning, FailOnNote, FailOnNever:
+		return FailureLevel(level), nil
+	}
+	return "", errors.Errorf("invalid failure level %q (must be one of error, warning, note, or never)", level)
+}
+
+// The conclusion of checks without findings.
+type NoFindingsConclusion string
+
+const (
+	NoFindingsSuccess NoFindingsConclusion = "success"
+	NoFindingsNeutral NoFindingsConclusion = "neutral"
+	// post no check when a tool reports no results (checks whose findings were all filtered out are still successful)
+	NoFindingsSkip NoFindingsConclusion = "skip"
+)
+
+func ParseNoFindingsConclusion(conclusion string) (NoFindingsConclusion, error) {
+	switch NoFindingsConclusion(conclusion) {
+	case NoFindingsSuccess, NoFindingsNeutral, NoFindingsSkip:
+		return NoFindingsConclusion(conclusion), nil
+	}
+	return "", errors.Errorf("invalid no findings conclusion %q (must be one of success, neutral, or skip)", conclusion)
+}
+
+// Decides the conclusion of a check from its new (rather than unchanged) annotations. The zero value fails checks
+// with any new error, which is GitHub's own behavior.
+type ConclusionPolicy struct {
+	// new findings at or above this level fail the check (defaults to error)
+	FailOn FailureLevel
+	// the number of new findings of each level tolerated before failing the check, overriding FailOn (nil when unset)
+	MaxErrors, MaxWarnings, MaxNotes *int
+	// the conclusion of checks with no findings (e.g., every finding was outside the diff), defaults to success
+	NoFindings NoFindingsConclusion
+}
+
+// The number of new findings of a level tolerated before failing the check, or -1 when there is no limit.
+func (policy ConclusionPolicy) limit(level int) int {
+	maxCount := map[int]*int{failureLevel: policy.MaxErrors, warningLevel: policy.MaxWarnings, noticeLevel: policy.MaxNotes}[level]
+	if maxCount != nil {
+		return *maxCount
+	}
+
+	failOn := policy.FailOn
+	if failOn == "" {
+		failOn = FailOnError
+	}
+	lowestFailingLevel := map[FailureLevel]int{FailOnError: failureLevel, FailOnWarning: warningLevel, FailOnNote: noticeLevel, FailOnNever: -1}[failOn]
+	// levels are ordered from failure (lowest) to notice (highest)
+	if level <= lowestFailingLevel {
+		return 0
+	}
+	return -1
+}
+
+// Compute the conclusion of a check, and the reason it failed (if it did). Checks which do not fail are neutral when
+// there are new errors or warnings, and successful otherwise.
+func computeConclusion(annotations []*Annotation, policy ConclusionPolicy) (conclusion string, reason string) {
+	// Can be one of "success", "failure", "neutral", "cancelled", "skipped", "timed_out", or "action_required".
+	conclusion = "success"
+
+	counts := make(map[int]int)
+	for _, annotation := range annotations {
+		if annotation.unchanged {
+			continue
+		}
+		counts[annotation.level] += 1
+		if annotation.level == failureLevel || annotation.level == warningLevel {
+			conclusion = "neutral"
+		}
+	}
+
+	var reasons []string
+	for _, level := range []int{failureLevel, warningLevel, noticeLevel} {
+		limit := policy.limit(level)
+		if limit < 0 || counts[level] <= limit {
+			continue
+		}
+		if limit == 0 {
+			reasons = append(reasons, fmt.Sprintf("%d new %s findings", counts[level], strings.ToLower(levelName(level))))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("%d new %s findings (more than the %d allowed)", counts[level], strings.ToLower(levelName(level)), limit))
+		}
+	}
+	if len(reasons) > 0 {
+		return "failure", fmt.Sprintf("This check failed because of %s.", strings.Join(reasons, " and "))
+	}
+	return conclusion, ""
+}
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestComputeConclusionWithPolicy(t *testing.T) {
+	count := func(value int) *int { return &value }
+	notice := &Annotation{level: noticeLevel}
+	warning := &Annotation{level: warningLevel}
+	failure := &Annotation{level: failureLevel}
+	unchangedWarning := &Annotation{level: warningLevel, unchanged: true}
+
+	tests := []struct {
+		name        string
+		annotations []*Annotation
+		policy      ConclusionPolicy
+		conclusion  string
+		reason      string
+	}{
+		{"fail on warning with a warning", []*Annotation{notice, warning}, ConclusionPolicy{FailOn: FailOnWarning}, "failure", "This check failed because of 1 new warning findings."},
+		{"fail on warning with an unchanged warning", []*Annotation{unchangedWarning}, ConclusionPolicy{FailOn: FailOnWarning}, "success", ""},
+		{"fail on note with a notice", []*Annotation{notice}, ConclusionPolicy{FailOn: FailOnNote}, "failure", "This check failed because of 1 new notice findings."},
+		{"fail on error with a warning", []*Annotation{warning}, ConclusionPolicy{FailOn: FailOnError}, "neutral", ""},
+		{"never fail with a failure", []*Annotation{failure, notice}, ConclusionPolicy{FailOn: FailOnNever}, "neutral", ""},
+		{"never fail with only notices", []*Annotation{notice}, ConclusionPolicy{FailOn: FailOnNever}, "success", ""},
+		{"errors within their maximum", []*Annotation{failure, failure}, ConclusionPolicy{MaxErrors: count(2)}, "neutral", ""},
+		{"warnings above their maximum", []*Annotation{warning, warning, warning}, ConclusionPolicy{FailOn: FailOnNever, MaxWarnings: count(2)}, "failure", "This check failed because of 3 new warning findings (more than the 2 allowed)."},
+		{"errors and notices above their maximums", []*Annotation{failure, notice, notice}, ConclusionPolicy{MaxNotes: count(1)}, "failure", "This check failed because of 1 new failure findings and 2 new notice findings (more than the 1 allowed)."},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s returns %s", tt.name, tt.conclusion), func(t *testing.T) {
+			conclusion, reason := computeConclusion(tt.annotations, tt.policy)
+			if conclusion != tt.conclusion || reason != tt.reason {
+				t.Errorf("expected %q (%q) but got %q (%q)", tt.conclusion, tt.reason, conclusion, reason)
+			}
+		})
+	}
+}
+
+func TestParseFailureLevel(t *testing.T) {
+	for _, level := range []string{"error", "warning", "note", "never"} {
+		if got, err := ParseFailureLevel(level); err != nil || string(got) != level {
+			t.Errorf("expected %q but got %q (%v)", level, got, err)
+		}
+	}
+	if _, err := ParseFailureLevel("critical"); err == nil {
+		t.Errorf("expected an error for an invalid failure level")
+	}
+}
+
+func TestParseNoFindingsConclusion(t *testing.T) {
+	for _, conclusion := range []string{"success", "neutral", "skip"} {
+		if got, err := ParseNoFindingsConclusion(conclusion); err != nil || string(got) != conclusion {
+			t.Errorf("expected %q but got %q (%v)", conclusion, got, err)
+		}
+	}
+	if _, err := ParseNoFindingsConclusion("failure"); err == nil {
+		t.Errorf("expected an error for an invalid no findings conclusion")
+	}
+}
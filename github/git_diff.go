@@ -0,0 +1,111 @@
+package github
+
+import (
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Load the files changed between the merge base of baseSHA and headSHA, and headSHA, from a local git checkout.
+func loadFilesFromGit(gitDirectory string, baseSHA string, headSHA string) ([]*pullRequestFile, error) {
+	diff, err := gitDiff(gitDirectory, baseSHA, headSHA)
+	if err != nil {
+		return nil, err
+	}
+	return gitDiffToFiles(diff)
+}
+
+func gitDiff(gitDirectory string, baseSHA string, headSHA string) (string, error) {
+	command := exec.Command(
+		"git", "-C", gitDirectory, "-c", "core.quotePath=false",
+		"diff", "--no-color", "--no-ext-diff", "--find-renames", baseSHA+"..."+headSHA, "--",
+	)
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		// most commonly a shallow clone which does not contain the base commit
+		return "", errors.Wrapf(err, "failed to diff %s...%s in %q (both commits must be fetched): %s", baseSHA, headSHA, gitDirectory, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Split the output of git diff into files, each with the hunks of its patch (in the same form as the pull request
+// files API). Deleted files are skipped, while files without hunks (e.g., renamed-only or binary files) have no patch.
+func gitDiffToFiles(diff string) ([]*pullRequestFile, error) {
+	var files []*pullRequestFile
+	var filename, previousFilename, status string
+	var patch []string
+
+	flush := func() error {
+		if filename == "" || status == "removed" {
+			return nil
+		}
+		file, err := newPullRequestFile(filename, previousFilename, status, strings.TrimRight(strings.Join(patch, "\n"), "\n"))
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	}
+
+	// whether a rename to or copy to line named the file, which is unambiguous and so preferred over the +++ line
+	named := false
+	inHunks := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			filename, previousFilename, status, patch, named, inHunks = "", "", "modified", nil, false, false
+			// the paths in the header are ambiguous when they contain " b/", so are only used when no other line names the file
+			if index := strings.LastIndex(line, " \"b/"); index >= 0 {
+				filename = strings.TrimPrefix(unquoteGitPath(line[index+1:]), "b/")
+			} else if index := strings.LastIndex(line, " b/"); index >= 0 {
+				filename = line[index+len(" b/"):]
+			}
+		case inHunks:
+			patch = append(patch, line)
+		case strings.HasPrefix(line, "@@ "):
+			inHunks = true
+			patch = append(patch, line)
+		case strings.HasPrefix(line, "new file mode "):
+			status = "added"
+		case strings.HasPrefix(line, "deleted file mode "):
+			status = "removed"
+		case strings.HasPrefix(line, "rename from "):
+			status, previousFilename = "renamed", unquoteGitPath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			filename, named = unquoteGitPath(strings.TrimPrefix(line, "rename to ")), true
+		case strings.HasPrefix(line, "copy from "):
+			status, previousFilename = "copied", unquoteGitPath(strings.TrimPrefix(line, "copy from "))
+		case strings.HasPrefix(line, "copy to "):
+			filename, named = unquoteGitPath(strings.TrimPrefix(line, "copy to ")), true
+		case strings.HasPrefix(line, "+++ ") && !named:
+			if path := unquoteGitPath(strings.TrimPrefix(line, "+++ ")); path != "/dev/null" {
+				filename = strings.TrimPrefix(path, "b/")
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Read a path as git writes it in a diff: followed by a tab on the ---/+++ lines when it contains a space, and
+// C-quoted (e.g., "say \"hi\".py") when it contains quotes, backslashes, or control characters.
+func unquoteGitPath(path string) string {
+	path = strings.TrimSuffix(path, "\t")
+	if len(path) >= 2 && strings.HasPrefix(path, "\"") && strings.HasSuffix(path, "\"") {
+		// git's escapes (e.g., \t, \", and octal bytes) are a subset of Go's
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
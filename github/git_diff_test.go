@@ -0,0 +1,172 @@
+package github
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGitDiffToFiles(t *testing.T) {
+	// from git diff, which ends paths containing spaces with a tab on the ---/+++ lines, and quotes unusual paths
+	diff := `diff --git a/src/main.go b/src/main.go
+index 1111111..2222222 100644
+--- a/src/main.go
++++ b/src/main.go
+@@ -1,3 +1,4 @@ package main
+ import "fmt"
++import "os"
+
+ func main() {
+@@ -20,2 +21,3 @@ func main() {
+ 	fmt.Println()
++	os.Exit(1)
+ }
+diff --git a/old.go b/old.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package old
+-
+diff --git a/logo.png b/logo.png
+index 4444444..5555555 100644
+Binary files a/logo.png and b/logo.png differ
+diff --git a/old name.py b/new name.py
+similarity index 66%
+rename from old name.py
+rename to new name.py
+index b77b4eb..04ec35a 100644
+--- a/old name.py	
++++ b/new name.py	
+@@ -1,2 +1,3 @@
+ x
+ y
++z
+diff --git "a/say \"hi\".py" "b/say \"hi\".py"
+index bca70f3..8a08eba 100644
+--- "a/say \"hi\".py"	
++++ "b/say \"hi\".py"	
+@@ -1 +1,2 @@
+ q
++r
+diff --git a/src/unmoved.go b/src/moved.go
+similarity index 100%
+rename from src/unmoved.go
+rename to src/moved.go
+diff --git a/src/empty.go b/src/empty.go
+new file mode 100644
+index 0000000..e69de29
+`
+
+	files, err := gitDiffToFiles(diff)
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	expected := []*pullRequestFile{
+		{
+			filename:   "src/main.go",
+			patch:      "@@ -1,3 +1,4 @@ package main\n import \"fmt\"\n+import \"os\"\n\n func main() {\n@@ -20,2 +21,3 @@ func main() {\n \tfmt.Println()\n+\tos.Exit(1)\n }",
+			status:     "modified",
+			lineBounds: []lineBound{{1, 4}, {21, 23}},
+			addedLines: []int{2, 22},
+		},
+		{
+			filename: "logo.png",
+			status:   "modified",
+		},
+		{
+			filename:         "new name.py",
+			previousFilename: "old name.py",
+			status:           "renamed",
+			patch:            "@@ -1,2 +1,3 @@\n x\n y\n+z",
+			lineBounds:       []lineBound{{1, 3}},
+			addedLines:       []int{3},
+		},
+		{
+			filename:   "say \"hi\".py",
+			status:     "modified",
+			patch:      "@@ -1 +1,2 @@\n q\n+r",
+			lineBounds: []lineBound{{1, 2}},
+			addedLines: []int{2},
+		},
+		{
+			filename:         "src/moved.go",
+			previousFilename: "src/unmoved.go",
+			status:           "renamed",
+		},
+		{
+			filename: "src/empty.go",
+			status:   "added",
+		},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %s but got %s", pullRequestFilesString(expected), pullRequestFilesString(files))
+	}
+}
+
+func TestLoadFilesFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		command := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := command.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s", args, output)
+		}
+		return string(output)
+	}
+	write := func(contents string) {
+		if err := os.WriteFile(filepath.Join(dir, "main file.py"), []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "--quiet")
+	write("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n")
+	run("add", "main file.py")
+	run("commit", "--quiet", "-m", "base")
+	baseSHA := run("rev-parse", "HEAD")[:40]
+	write("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n")
+	run("commit", "--quiet", "-am", "head")
+	headSHA := run("rev-parse", "HEAD")[:40]
+
+	files, err := loadFilesFromGit(dir, baseSHA, headSHA)
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	if len(files) != 1 || files[0].filename != "main file.py" || !reflect.DeepEqual(files[0].lineBounds, []lineBound{{8, 11}}) {
+		t.Errorf("expected main file.py with patches on (8, 11) but got %s", pullRequestFilesString(files))
+	}
+
+	if _, err := loadFilesFromGit(dir, "0000000000000000000000000000000000000000", headSHA); err == nil {
+		t.Error("expected an error for a missing base commit but received none")
+	}
+}
+
+func TestUnquoteGitPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"b/main.py", "b/main.py"},
+		{"b/a b.py\t", "b/a b.py"},
+		{`"b/say \"hi\".py"`, `b/say "hi".py`},
+		{`"b/tab\there.py"` + "\t", "b/tab\there.py"},
+		{`"b/h\303\251llo.py"`, "b/héllo.py"},
+		{`"b/unterminated`, `"b/unterminated`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := unquoteGitPath(tt.path); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package github
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v47/github"
+	"github.com/pkg/errors"
+)
+
+// Translates lines of the scanned commit to the matching lines of a later commit (e.g., the pull request's latest
+// head), using the diff between the two.
+type lineTranslation struct {
+	fromSHA, toSHA string
+	// changed files, by their path on the scanned commit
+	files map[string]*fileTranslation
+}
+
+type fileTranslation struct {
+	// the file's path on the later commit (differs from the scanned path when renamed)
+	path    string
+	removed bool
+	// patches may be omitted (e.g., for large files), in which case no line can be translated
+	hasPatch bool
+	hunks    []hunkTranslation
+}
+
+type hunkTranslation struct {
+	// the first old line in the hunk, and the old and new lines following the hunk
+	oldFirst, oldNext, newNext int
+	// old lines which are unchanged by the hunk, mapped to their new line numbers
+	unchanged map[int]int
+}
+
+// Findings cannot be moved onto a later commit, as the comparison does not describe how every scanned line moved. The
+// reason completes the check summary's note "Findings were not moved onto ..., as".
+type untranslatableError struct {
+	reason string
+}
+
+func (err *untranslatableError) Error() string {
+	return "cannot move findings onto the later commit, as " + err.reason
+}
+
+// Load the translation from the scanned commit to a later commit via the compare API.
+func loadLineTranslation(client *github.Client, owner string, repo string, fromSHA string, toSHA string) (*lineTranslation, error) {
+	translation := &lineTranslation{fromSHA: fromSHA, toSHA: toSHA, files: make(map[string]*fileTranslation)}
+	// the comparison lists its files on its first page only
+	comparison, _, err := client.Repositories.CompareCommits(context.Background(), owner, repo, fromSHA, toSHA, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare %s...%s", fromSHA, toSHA)
+	}
+	if status := comparison.GetStatus(); status != "ahead" && status != "identical" {
+		// the compare API diffs from the merge base, which does not describe how the scanned lines moved
+		return nil, errors.Wrapf(&untranslatableError{"it does not contain this commit (e.g., the pull request was force-pushed)"}, "%s is %s %s", toSHA, status, fromSHA)
+	}
+	if len(comparison.Files) >= maxComparedFiles {
+		// files missing from the comparison would be treated as unchanged
+		return nil, &untranslatableError{fmt.Sprintf("GitHub's compare API lists at most %d of the files changed since this commit", maxComparedFiles)}
+	}
+	for _, file := range comparison.Files {
+		if err := translation.addFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return translation, nil
+}
+
+func (translation *lineTranslation) addFile(file *github.CommitFile) error {
+	path := file.GetFilename()
+	scannedPath := path
+	if file.GetPreviousFilename() != "" {
+		scannedPath = file.GetPreviousFilename()
+	}
+	if _, found := translation.files[scannedPath]; found {
+		return nil
+	}
+
+	fileTranslation := &fileTranslation{path: path, removed: file.GetStatus() == "removed", hasPatch: file.Patch != nil}
+	if file.Patch != nil {
+		hunks, err := patchToHunkTranslations(file.GetPatch())
+		if err != nil {
+			return errors.Wrapf(err, "failed to translate lines of file %q", path)
+		}
+		fileTranslation.hunks = hunks
+	} else if file.GetStatus() == "renamed" && file.GetChanges() == 0 {
+		// renamed without changes, so every line is unchanged
+		fileTranslation.hasPatch = true
+	}
+	translation.files[scannedPath] = fileTranslation
+	return nil
+}
+
+func patchToHunkTranslations(patch string) ([]hunkTranslation, error) {
+	var hunks []hunkTranslation
+	var current *hunkTranslation
+	oldLine, newLine := 0, 0
+
+	finishHunk := func() {
+		if current != nil {
+			current.oldNext, current.newNext = oldLine, newLine
+			hunks = append(hunks, *current)
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	for scanner.Scan() {
+		text := scanner.Text()
+		header, isHeader, err := parseHunkHeader(text)
+		if err != nil {
+			return nil, err
+		}
+		if isHeader {
+			finishHunk()
+			// an empty side of a hunk (e.g., @@ -5,0 +6,2 @@) is positioned after its start line
+			oldLine, newLine = header.oldStart, header.newStart
+			if header.oldLength == 0 {
+				oldLine += 1
+			}
+			if header.newLength == 0 {
+				newLine += 1
+			}
+			current = &hunkTranslation{oldFirst: oldLine, unchanged: make(map[int]int)}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "+"):
+			newLine += 1
+		case strings.HasPrefix(text, "-"):
+			oldLine += 1
+		case strings.HasPrefix(text, "\\"): // "\ No newline at end of file"
+		default:
+			current.unchanged[oldLine] = newLine
+			oldLine += 1
+			newLine += 1
+		}
+	}
+	finishHunk()
+
+	return hunks, nil
+}
+
+// Translate a line of a file on the scanned commit to its path and line on the later commit. Lines which were removed
+// or modified (and lines of removed files) cannot be translated.
+func (translation *lineTranslation) translate(path string, line int) (string, int, bool) {
+	file, found := translation.files[path]
+	if !found {
+		return path, line, true
+	}
+	if file.removed || !file.hasPatch {
+		return "", 0, false
+	}
+
+	offset := 0
+	for _, hunk := range file.hunks {
+		if line < hunk.oldFirst {
+			break
+		}
+		if line < hunk.oldNext {
+			newLine, unchanged := hunk.unchanged[line]
+			return file.path, newLine, unchanged
+		}
+		offset = hunk.newNext - hunk.oldNext
+	}
+	return file.path, line + offset, true
+}
+
+// Move annotations (and their related locations) from the scanned commit onto the later commit, returning the
+// annotations whose lines no longer exist.
+func (translation *lineTranslation) translateAnnotations(annotations []*Annotation) (translated []*Annotation, untranslatable []*Annotation) {
+	for _, annotation := range annotations {
+		path, startLine, startFound := translation.translate(annotation.fileName, annotation.startLine)
+		_, endLine, endFound := translation.translate(annotation.fileName, annotation.endLine)
+		if !startFound || !endFound || endLine < startLine {
+			untranslatable = append(untranslatable, annotation)
+			continue
+		}
+
+		annotation.moveTo(path, startLine, endLine)
+		for i := range annotation.relatedLocations {
+			translation.translateLocation(&annotation.relatedLocations[i])
+		}
+		for i := range annotation.codeFlows {
+			for j := range annotation.codeFlows[i].Steps {
+				translation.translateLocation(&annotation.codeFlows[i].Steps[j])
+			}
+		}
+		translated = append(translated, annotation)
+	}
+	return translated, untranslatable
+}
+
+// Translate a related location in place, leaving it unchanged when its lines no longer exist.
+func (translation *lineTranslation) translateLocation(location *AnnotationLocation) {
+	path, startLine, startFound := translation.translate(location.Path, location.StartLine)
+	_, endLine, endFound := translation.translate(location.Path, location.EndLine)
+	if startFound && endFound && endLine >= startLine {
+		location.Path, location.StartLine, location.EndLine = path, startLine, endLine
+	}
+}
+
+// Translate the lines replaced by suggestions, dropping suggestions whose lines no longer exist.
+func (translation *lineTranslation) translateSuggestions(suggestions []*Suggestion) []*Suggestion {
+	var translated []*Suggestion
+	for _, suggestion := range suggestions {
+		path := suggestion.Path
+		var replacements []SuggestionReplacement
+		for _, replacement := range suggestion.Replacements {
+			startPath, startLine, startFound := translation.translate(suggestion.Path, replacement.StartLine)
+			_, endLine, endFound := translation.translate(suggestion.Path, replacement.EndLine)
+			if !startFound || !endFound || endLine < startLine {
+				replacements = nil
+				break
+			}
+			path, replacement.StartLine, replacement.EndLine = startPath, startLine, endLine
+			replacements = append(replacements, replacement)
+		}
+		if len(replacements) == 0 {
+			continue
+		}
+
+		translatedSuggestion := *suggestion
+		translatedSuggestion.Path = path
+		translatedSuggestion.Replacements = replacements
+		translated = append(translated, &translatedSuggestion)
+	}
+	return translated
+}
@@ -0,0 +1,163 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v47/github"
+	"github.com/pkg/errors"
+)
+
+func testLineTranslation(t *testing.T, files ...*github.CommitFile) *lineTranslation {
+	translation := &lineTranslation{fromSHA: "abc123", toSHA: "def456", files: make(map[string]*fileTranslation)}
+	for _, file := range files {
+		if err := translation.addFile(file); err != nil {
+			t.Fatalf("expected no error but received %q", err)
+		}
+	}
+	return translation
+}
+
+func TestLineTranslationTranslate(t *testing.T) {
+	// line 3 is modified, two lines are inserted after line 5, and line 10 is removed
+	patch := `@@ -1,6 +1,8 @@
+ one
+ two
+-three
++THREE
+ four
+ five
++inserted
++inserted
+ six
+@@ -9,3 +11,2 @@ def f():
+ nine
+-ten
+ eleven`
+	translation := testLineTranslation(t,
+		&github.CommitFile{Filename: github.String("src/main.py"), Status: github.String("modified"), Patch: &patch},
+		&github.CommitFile{Filename: github.String("src/new.py"), PreviousFilename: github.String("src/old.py"), Status: github.String("renamed"), Changes: github.Int(0)},
+		&github.CommitFile{Filename: github.String("src/gone.py"), Status: github.String("removed"), Patch: github.String("@@ -1 +0,0 @@\n-gone")},
+		&github.CommitFile{Filename: github.String("src/large.py"), Status: github.String("modified"), Changes: github.Int(9000)},
+	)
+
+	tests := []struct {
+		name         string
+		path         string
+		line         int
+		expectedPath string
+		expectedLine int
+		expectedOK   bool
+	}{
+		{"unchanged file", "src/other.py", 7, "src/other.py", 7, true},
+		{"context line before change", "src/main.py", 2, "src/main.py", 2, true},
+		{"modified line", "src/main.py", 3, "", 0, false},
+		{"context line after insertion", "src/main.py", 6, "src/main.py", 8, true},
+		{"line between hunks", "src/main.py", 8, "src/main.py", 10, true},
+		{"removed line", "src/main.py", 10, "", 0, false},
+		{"line after removal", "src/main.py", 11, "src/main.py", 12, true},
+		{"line after all hunks", "src/main.py", 40, "src/main.py", 41, true},
+		{"renamed file", "src/old.py", 5, "src/new.py", 5, true},
+		{"removed file", "src/gone.py", 1, "", 0, false},
+		{"file without patch", "src/large.py", 1, "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, line, ok := translation.translate(tt.path, tt.line)
+			if ok != tt.expectedOK || (ok && (path != tt.expectedPath || line != tt.expectedLine)) {
+				t.Errorf("expected %s:%d (%t) but got %s:%d (%t)", tt.expectedPath, tt.expectedLine, tt.expectedOK, path, line, ok)
+			}
+		})
+	}
+}
+
+func TestLineTranslationInsertionOnly(t *testing.T) {
+	translation := testLineTranslation(t, &github.CommitFile{Filename: github.String("a.py"), Patch: github.String("@@ -5,0 +6,2 @@\n+x\n+y")})
+	for old, expected := range map[int]int{5: 5, 6: 8} {
+		if _, line, ok := translation.translate("a.py", old); !ok || line != expected {
+			t.Errorf("expected line %d to translate to %d but got %d (%t)", old, expected, line, ok)
+		}
+	}
+}
+
+func TestTranslateAnnotations(t *testing.T) {
+	translation := testLineTranslation(t, &github.CommitFile{Filename: github.String("src/new.py"), PreviousFilename: github.String("src/old.py"), Status: github.String("renamed"), Patch: github.String("@@ -1,2 +1,3 @@\n+import os\n one\n-two")})
+
+	moved, _ := CreateAnnotation("src/old.py", 1, 1, "warning", "rule", "message")
+	moved.AddRelatedLocations(AnnotationLocation{Path: "src/old.py", StartLine: 1, EndLine: 1}, AnnotationLocation{Path: "src/old.py", StartLine: 2, EndLine: 2})
+	removed, _ := CreateAnnotation("src/old.py", 2, 2, "warning", "rule", "message")
+
+	translated, untranslatable := translation.translateAnnotations([]*Annotation{moved, removed})
+	if len(translated) != 1 || translated[0] != moved || len(untranslatable) != 1 || untranslatable[0] != removed {
+		t.Fatalf("expected one translated and one untranslatable annotation but got %v and %v", translated, untranslatable)
+	}
+	if moved.fileName != "src/new.py" || moved.startLine != 2 || *moved.githubAnnotation.Path != "src/new.py" || *moved.githubAnnotation.StartLine != 2 || *moved.githubAnnotation.EndLine != 2 {
+		t.Errorf("expected the annotation to move to src/new.py:2 but got %v", moved)
+	}
+	expectedLocations := []AnnotationLocation{{Path: "src/new.py", StartLine: 2, EndLine: 2}, {Path: "src/old.py", StartLine: 2, EndLine: 2}}
+	for i, expected := range expectedLocations {
+		if moved.relatedLocations[i] != expected {
+			t.Errorf("expected related location %v but got %v", expected, moved.relatedLocations[i])
+		}
+	}
+}
+
+func TestTranslateSuggestions(t *testing.T) {
+	translation := testLineTranslation(t, &github.CommitFile{Filename: github.String("a.py"), Patch: github.String("@@ -1,2 +1,3 @@\n+import os\n one\n-two")})
+
+	movable := &Suggestion{Path: "a.py", Replacements: []SuggestionReplacement{{StartLine: 1, EndLine: 1, Text: "ONE"}}}
+	removed := &Suggestion{Path: "a.py", Replacements: []SuggestionReplacement{{StartLine: 1, EndLine: 1}, {StartLine: 2, EndLine: 2}}}
+
+	got := translation.translateSuggestions([]*Suggestion{movable, removed})
+	if len(got) != 1 {
+		t.Fatalf("expected one suggestion but got %d", len(got))
+	}
+	if got[0].Replacements[0].StartLine != 2 || got[0].Replacements[0].EndLine != 2 || got[0].Replacements[0].Text != "ONE" {
+		t.Errorf("expected the replacement to move to line 2 but got %v", got[0].Replacements[0])
+	}
+	if movable.Replacements[0].StartLine != 1 {
+		t.Errorf("expected the original suggestion to be unchanged but got %v", movable.Replacements[0])
+	}
+}
+
+func TestLoadLineTranslationStatus(t *testing.T) {
+	file := `{"filename": "src/main.py", "status": "modified", "patch": "@@ -1 +1,2 @@\n a\n+b"}`
+	tests := []struct {
+		name           string
+		status         string
+		files          int
+		translates     bool
+		expectedReason string
+	}{
+		{"ahead", "ahead", 1, true, ""},
+		{"identical", "identical", 1, true, ""},
+		{"diverged", "diverged", 1, false, "it does not contain this commit"},
+		{"behind", "behind", 1, false, "it does not contain this commit"},
+		{"too many files", "ahead", maxComparedFiles, false, "lists at most 300"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make([]string, tt.files)
+			for i := range files {
+				files[i] = strings.Replace(file, "src/main.py", fmt.Sprintf("src/%d.py", i), 1)
+			}
+			files[0] = file
+			body := `{"status": "` + tt.status + `", "files": [` + strings.Join(files, ",") + `]}`
+			response := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(bytes.NewReader([]byte(body)))}
+			client := github.NewClient(&http.Client{Transport: &fakeTransport{responses: []*http.Response{response}, errs: []error{nil}}})
+
+			translation, err := loadLineTranslation(client, "o", "r", "abc123", "def456")
+			if tt.translates && (err != nil || translation.files["src/main.py"] == nil) {
+				t.Errorf("expected a translation of src/main.py but got %v (%v)", translation, err)
+			}
+			var untranslatable *untranslatableError
+			if !tt.translates && (!errors.As(err, &untranslatable) || !strings.Contains(untranslatable.reason, tt.expectedReason)) {
+				t.Errorf("expected no translation because %q but received %v", tt.expectedReason, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package github
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+
+	"github.com/google/go-github/v47/github"
+)
+
+// GitHub accepts at most 50 annotations per request, so later annotations are added by updating the check run.
+const maxAnnotationsPerPage = 50
+
+// How far an attempt to post the annotations of a check run got, so that it can be resumed after a failure (rather
+// than creating a new check run, or posting annotations twice).
+type PostingProgress struct {
+	// the commit and check the annotations were posted to, which a resumed attempt must match (e.g., a later push can
+	// have the same findings)
+	HeadSHA    string `json:"head_sha"`
+	CheckName  string `json:"check_name"`
+	CheckRunID int64  `json:"check_run_id"`
+	// the number of pages of annotations posted (a check run without annotations has one page)
+	PostedPages int `json:"posted_pages"`
+	// identifies the annotations being posted, which a resumed attempt must match
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Sort annotations by location, and then by content.
+func sortAnnotations(annotations []*Annotation) {
+	sort.SliceStable(annotations, func(i, j int) bool {
+		a, b := annotations[i], annotations[j]
+		if a.fileName != b.fileName {
+			return a.fileName < b.fileName
+		}
+		if a.startLine != b.startLine {
+			return a.startLine < b.startLine
+		}
+		if a.endLine != b.endLine {
+			return a.endLine < b.endLine
+		}
+		aHash, bHash := a.Hash(), b.Hash()
+		return hex.EncodeToString(aHash[:]) < hex.EncodeToString(bHash[:])
+	})
+}
+
+// Split annotations into pages of GitHub annotations.
+func annotationPages(annotations []*Annotation) [][]*github.CheckRunAnnotation {
+	pages := [][]*github.CheckRunAnnotation{}
+	for i := 0; i < len(annotations); i += maxAnnotationsPerPage {
+		pageEnd := i + maxAnnotationsPerPage
+		if pageEnd > len(annotations) {
+			pageEnd = len(annotations)
+		}
+
+		page := []*github.CheckRunAnnotation{}
+		for _, annotation := range annotations[i:pageEnd] {
+			page = append(page, annotation.githubAnnotation)
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// Identify a list of annotations, in order, including their messages.
+func annotationsFingerprint(annotations []*Annotation) string {
+	hash := md5.New()
+	for _, annotation := range annotations {
+		annotationHash := annotation.Hash()
+		hash.Write(annotationHash[:])
+		messageHash := md5.Sum([]byte(annotation.githubAnnotation.GetMessage()))
+		hash.Write(messageHash[:])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Whether an attempt which made this progress can be resumed by an attempt posting the given annotations.
+func (progress *PostingProgress) resumes(headSHA string, checkName string, fingerprint string) bool {
+	return progress != nil && progress.CheckRunID != 0 && progress.PostedPages >= 1 &&
+		progress.HeadSHA == headSHA && progress.CheckName == checkName && progress.Fingerprint == fingerprint
+}
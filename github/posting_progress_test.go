@@ -0,0 +1,101 @@
+package github
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSortAnnotations(t *testing.T) {
+	first, _ := CreateAnnotation("a.py", 3, 3, "warning", "rule", "message")
+	second, _ := CreateAnnotation("a.py", 10, 10, "warning", "rule", "message")
+	third, _ := CreateAnnotation("b.py", 1, 1, "warning", "rule", "message")
+
+	annotations := []*Annotation{third, second, first}
+	sortAnnotations(annotations)
+	for i, expected := range []*Annotation{first, second, third} {
+		if annotations[i] != expected {
+			t.Errorf("expected %v at %d but got %v", expected, i, annotations[i])
+		}
+	}
+}
+
+func TestAnnotationPages(t *testing.T) {
+	tests := []struct {
+		count         int
+		expectedPages []int
+	}{
+		{0, []int{}},
+		{1, []int{1}},
+		{50, []int{50}},
+		{51, []int{50, 1}},
+		{120, []int{50, 50, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.count), func(t *testing.T) {
+			var annotations []*Annotation
+			for i := 0; i < tt.count; i++ {
+				annotation, _ := CreateAnnotation("a.py", i+1, i+1, "warning", "rule", "message")
+				annotations = append(annotations, annotation)
+			}
+
+			pages := annotationPages(annotations)
+			if len(pages) != len(tt.expectedPages) {
+				t.Fatalf("expected %d pages but got %d", len(tt.expectedPages), len(pages))
+			}
+			for i, page := range pages {
+				if len(page) != tt.expectedPages[i] {
+					t.Errorf("expected %d annotations on page %d but got %d", tt.expectedPages[i], i, len(page))
+				}
+			}
+		})
+	}
+}
+
+func TestAnnotationsFingerprint(t *testing.T) {
+	first, _ := CreateAnnotation("a.py", 3, 3, "warning", "rule", "message")
+	second, _ := CreateAnnotation("a.py", 10, 10, "warning", "rule", "message")
+	sameAsFirst, _ := CreateAnnotation("a.py", 3, 3, "warning", "rule", "message")
+	otherMessage, _ := CreateAnnotation("a.py", 3, 3, "warning", "rule", "another message")
+
+	fingerprint := annotationsFingerprint([]*Annotation{first, second})
+	if fingerprint != annotationsFingerprint([]*Annotation{sameAsFirst, second}) {
+		t.Error("expected equal annotations to have the same fingerprint")
+	}
+	if fingerprint == annotationsFingerprint([]*Annotation{second, first}) {
+		t.Error("expected annotations in a different order to have a different fingerprint")
+	}
+	if fingerprint == annotationsFingerprint([]*Annotation{first}) {
+		t.Error("expected different annotations to have a different fingerprint")
+	}
+	if fingerprint == annotationsFingerprint([]*Annotation{otherMessage, second}) {
+		t.Error("expected annotations with different messages to have a different fingerprint")
+	}
+}
+
+func TestPostingProgressResumes(t *testing.T) {
+	progress := &PostingProgress{HeadSHA: "abc123", CheckName: "semgrep", CheckRunID: 42, PostedPages: 3, Fingerprint: "f"}
+
+	tests := []struct {
+		name      string
+		progress  *PostingProgress
+		headSHA   string
+		checkName string
+		resumes   bool
+	}{
+		{"same attempt", progress, "abc123", "semgrep", true},
+		{"no progress", nil, "abc123", "semgrep", false},
+		{"later commit with the same findings", progress, "def456", "semgrep", false},
+		{"another check", progress, "abc123", "codeql", false},
+		{"nothing posted", &PostingProgress{HeadSHA: "abc123", CheckName: "semgrep", CheckRunID: 42, Fingerprint: "f"}, "abc123", "semgrep", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.progress.resumes(tt.headSHA, tt.checkName, "f"); got != tt.resumes {
+				t.Errorf("expected %t but got %t", tt.resumes, got)
+			}
+		})
+	}
+	if progress.resumes("abc123", "semgrep", "other annotations") {
+		t.Error("expected different annotations not to resume")
+	}
+}
@@ -3,6 +3,7 @@ package github
 import (
 	"bufio"
 	"context"
+	"math"
 	"strconv"
 	"strings"
 
@@ -10,9 +11,41 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Where the pull request's diff (used to filter annotations) is read from.
+type DiffSource string
+
+const (
+	// the compare API, diffing the pull request's base against the scanned commit (limited to 300 files, beyond which
+	// the pull request files API is used instead)
+	CompareDiffSource DiffSource = "compare"
+	// the pull request files API, which reflects the pull request's latest head rather than the scanned commit (limited
+	// to 3000 files, and omits patches of large files)
+	FilesDiffSource DiffSource = "files"
+	// a local git checkout containing both the base and scanned commits
+	GitDiffSource DiffSource = "git"
+)
+
+// GitHub's compare API lists at most this many files.
+const maxComparedFiles = 300
+
+func ParseDiffSource(source string) (DiffSource, error) {
+	switch DiffSource(source) {
+	case CompareDiffSource, FilesDiffSource, GitDiffSource:
+		return DiffSource(source), nil
+	}
+	return "", errors.Errorf("invalid diff source %q (must be one of compare, files, or git)", source)
+}
+
 type PullRequestConfiguration struct {
 	Owner, Repo string
 	Number      int
+	// Where the diff used to filter annotations is read from (defaults to the compare API)
+	DiffSource DiffSource
+	// The local checkout read by the git diff source
+	GitDirectory string
+	// When the pull request's head has moved past the scanned commit, move findings onto the matching lines of the head
+	// and annotate the head instead
+	TranslateToHead bool
 }
 
 type pullRequest struct {
@@ -21,6 +54,12 @@ type pullRequest struct {
 	details     *github.PullRequest
 	headSHA     string
 	files       []*pullRequestFile
+	// set when findings from the scanned commit are moved onto the pull request's latest head
+	translation *lineTranslation
+	// set when findings could not be moved onto the pull request's latest head, with the reason why
+	untranslatableHeadSHA, untranslatableReason string
+	// set when the comparison listed too many files, so the pull request's files were used instead
+	comparisonTruncated bool
 }
 
 type lineBound struct {
@@ -28,14 +67,104 @@ type lineBound struct {
 }
 type pullRequestFile struct {
 	filename, patch string
-	lineBounds      []lineBound
+	// the file's path on the base commit, when renamed (or copied)
+	previousFilename string
+	// one of added, removed, modified, renamed, copied, changed, or unchanged
+	status     string
+	lineBounds []lineBound
+	// the new line numbers of lines added (or modified) by the patch, in ascending order
+	addedLines []int
+}
+
+// How closely a finding must match the pull request's diff to be annotated.
+type FilterGranularity string
+
+const (
+	// any line of a changed file
+	FileFilterGranularity FilterGranularity = "file"
+	// any line of a hunk, including the unchanged context lines around each change
+	HunkFilterGranularity FilterGranularity = "hunk"
+	// only added (or modified) lines, optionally widened by a number of context lines
+	AddedLinesFilterGranularity FilterGranularity = "added"
+)
+
+func ParseFilterGranularity(granularity string) (FilterGranularity, error) {
+	switch FilterGranularity(granularity) {
+	case FileFilterGranularity, HunkFilterGranularity, AddedLinesFilterGranularity:
+		return FilterGranularity(granularity), nil
+	}
+	return "", errors.Errorf("invalid filter granularity %q (must be one of file, hunk, or added)", granularity)
+}
+
+// What happens to findings in files which are in the diff without a patch (e.g., renamed-only files, or files too
+// large for GitHub to return a patch for).
+type UnpatchedFilePolicy string
+
+const (
+	// never annotate findings in files without a patch
+	SkipUnpatchedFilePolicy UnpatchedFilePolicy = "skip"
+	// treat added, renamed, or copied files without a patch as entirely changed
+	WholeFileUnpatchedFilePolicy UnpatchedFilePolicy = "whole_file"
+)
+
+func ParseUnpatchedFilePolicy(policy string) (UnpatchedFilePolicy, error) {
+	switch UnpatchedFilePolicy(policy) {
+	case SkipUnpatchedFilePolicy, WholeFileUnpatchedFilePolicy:
+		return UnpatchedFilePolicy(policy), nil
+	}
+	return "", errors.Errorf("invalid unpatched file policy %q (must be one of skip or whole_file)", policy)
+}
+
+// Which lines of a changed file findings may be annotated on.
+type LineFilter struct {
+	Granularity FilterGranularity
+	// lines either side of each added line which are also annotated, for the added lines granularity
+	AddedLineContext int
+	UnpatchedFiles   UnpatchedFilePolicy
+}
+
+func newPullRequestFile(filename string, previousFilename string, status string, patch string) (*pullRequestFile, error) {
+	lineBounds, err := patchToLineBounds(patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate line bounds for file %q", filename)
+	}
+	addedLines, err := patchToAddedLines(patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find added lines for file %q", filename)
+	}
+	return &pullRequestFile{
+		filename:         filename,
+		patch:            patch,
+		previousFilename: previousFilename,
+		status:           status,
+		lineBounds:       lineBounds,
+		addedLines:       addedLines,
+	}, nil
 }
 
-func createPullRequest(client *github.Client, owner string, repo string, number int, headSHA string) (*pullRequest, error) {
+// The line bounds of the file which findings may be annotated on.
+func (file *pullRequestFile) boundsFor(filter LineFilter) []lineBound {
+	if file.patch == "" {
+		if filter.UnpatchedFiles == WholeFileUnpatchedFilePolicy && (file.status == "added" || file.status == "renamed" || file.status == "copied") {
+			return []lineBound{{start: 1, end: math.MaxInt}}
+		}
+		return nil
+	}
+
+	switch filter.Granularity {
+	case FileFilterGranularity:
+		return []lineBound{{start: 1, end: math.MaxInt}}
+	case AddedLinesFilterGranularity:
+		return addedLinesToLineBounds(file.addedLines, filter.AddedLineContext)
+	}
+	return file.lineBounds
+}
+
+func createPullRequest(client *github.Client, configuration PullRequestConfiguration, headSHA string) (*pullRequest, error) {
 	pr := &pullRequest{
-		owner:   owner,
-		repo:    repo,
-		number:  number,
+		owner:   configuration.Owner,
+		repo:    configuration.Repo,
+		number:  configuration.Number,
 		details: nil,
 		headSHA: headSHA,
 	}
@@ -43,14 +172,59 @@ func createPullRequest(client *github.Client, owner string, repo string, number
 		return nil, errors.Wrap(err, "failed to load pull request from GitHub")
 	}
 
-	// Load all files from PR (uses _latest_ files on PR, not limited by headSHA)
-	if err := pr.loadFiles(client); err != nil {
-		return nil, errors.Wrap(err, "failed to load files from GitHub")
+	if latestHeadSHA := pr.movedHeadSHA(); configuration.TranslateToHead && latestHeadSHA != "" {
+		translation, err := loadLineTranslation(client, pr.owner, pr.repo, headSHA, latestHeadSHA)
+		var untranslatable *untranslatableError
+		switch {
+		case errors.As(err, &untranslatable):
+			// annotate the scanned commit, noting why its findings were not moved
+			pr.untranslatableHeadSHA, pr.untranslatableReason = latestHeadSHA, untranslatable.reason
+		case err != nil:
+			return nil, errors.Wrap(err, "failed to load changes since the scanned commit")
+		default:
+			pr.translation = translation
+			pr.headSHA = latestHeadSHA
+		}
+	}
+
+	switch configuration.DiffSource {
+	case GitDiffSource:
+		files, err := loadFilesFromGit(configuration.GitDirectory, pr.details.GetBase().GetSHA(), pr.headSHA)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load files from git")
+		}
+		pr.files = files
+	case FilesDiffSource:
+		// Load all files from PR (uses _latest_ files on PR, not limited by headSHA)
+		if err := pr.loadFiles(client); err != nil {
+			return nil, errors.Wrap(err, "failed to load files from GitHub")
+		}
+	default:
+		if err := pr.loadComparedFiles(client); err != nil {
+			return nil, errors.Wrap(err, "failed to compare commits on GitHub")
+		}
+		if len(pr.files) >= maxComparedFiles {
+			// findings in files past the comparison's limit would be filtered out, so use the longer list of the pull
+			// request's files, even though it reflects the latest head rather than the scanned commit
+			pr.files, pr.comparisonTruncated = nil, true
+			if err := pr.loadFiles(client); err != nil {
+				return nil, errors.Wrap(err, "failed to load files from GitHub")
+			}
+		}
 	}
 
 	return pr, nil
 }
 
+// The pull request's latest head, when it has moved past the scanned commit (e.g., a push landed during the scan).
+func (pr *pullRequest) movedHeadSHA() string {
+	latestHeadSHA := pr.details.GetHead().GetSHA()
+	if latestHeadSHA == "" || latestHeadSHA == pr.headSHA {
+		return ""
+	}
+	return latestHeadSHA
+}
+
 func (pr *pullRequest) loadFromGitHub(client *github.Client) error {
 	details, _, err := client.PullRequests.Get(context.Background(), pr.owner, pr.repo, pr.number)
 	if err != nil {
@@ -88,10 +262,63 @@ func (pr *pullRequest) loadFiles(client *github.Client) error {
 	return nil
 }
 
-func (pr *pullRequest) filterAnnotations(annotations []*Annotation) []*Annotation {
+// Load the files changed between the merge base of the pull request's base and the scanned commit. The comparison lists
+// its files (at most 300) on its first page only, so later pages (of commits) are not loaded.
+func (pr *pullRequest) loadComparedFiles(client *github.Client) error {
+	base := pr.details.GetBase().GetSHA()
+	comparison, _, err := client.Repositories.CompareCommits(context.Background(), pr.owner, pr.repo, base, pr.headSHA, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to compare %s...%s", base, pr.headSHA)
+	}
+	files, err := sdkFilesToInternalFiles(comparison.Files)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert files")
+	}
+	pr.files = files
+	return nil
+}
+
+// Move annotations (and their related locations) reported on the previous path of a renamed file to its current path.
+func (pr *pullRequest) followRenames(annotations []*Annotation) {
+	currentFilenames := make(map[string]bool)
+	previousToCurrentFilename := make(map[string]string)
+	for _, file := range pr.files {
+		currentFilenames[file.filename] = true
+		if file.status == "renamed" && file.previousFilename != "" {
+			previousToCurrentFilename[file.previousFilename] = file.filename
+		}
+	}
+	currentFilename := func(filename string) (string, bool) {
+		if currentFilenames[filename] {
+			return filename, false
+		}
+		renamed, found := previousToCurrentFilename[filename]
+		return renamed, found
+	}
+
+	for _, annotation := range annotations {
+		if filename, renamed := currentFilename(annotation.fileName); renamed {
+			annotation.moveTo(filename, annotation.startLine, annotation.endLine)
+		}
+		for i, location := range annotation.relatedLocations {
+			if filename, renamed := currentFilename(location.Path); renamed {
+				annotation.relatedLocations[i].Path = filename
+			}
+		}
+		for i := range annotation.codeFlows {
+			for j, step := range annotation.codeFlows[i].Steps {
+				if filename, renamed := currentFilename(step.Path); renamed {
+					annotation.codeFlows[i].Steps[j].Path = filename
+				}
+			}
+		}
+	}
+}
+
+func (pr *pullRequest) filterAnnotations(annotations []*Annotation, filter LineFilter) []*Annotation {
 	fileToLineBounds := make(map[string][]lineBound)
 	for _, file := range pr.files {
-		fileToLineBounds[file.filename] = file.lineBounds
+		fileToLineBounds[file.filename] = file.boundsFor(filter)
 	}
 
 	var filteredAnnotations []*Annotation
@@ -113,29 +340,38 @@ func (pr *pullRequest) filterAnnotations(annotations []*Annotation) []*Annotatio
 	return filteredAnnotations
 }
 
+// Whether lines startLine through endLine of a file all fall inside a single range of the diff (e.g., so that a review
+// comment may be placed on them).
+func (pr *pullRequest) rangeInDiff(filename string, startLine int, endLine int) bool {
+	for _, file := range pr.files {
+		if file.filename != filename {
+			continue
+		}
+		for _, bound := range file.lineBounds {
+			if startLine >= bound.start && endLine <= bound.end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 /* * * * * Helpers * * * * */
 
 func sdkFilesToInternalFiles(sdkFiles []*github.CommitFile) ([]*pullRequestFile, error) {
 	var internalFiles []*pullRequestFile
 
 	for _, file := range sdkFiles {
-		if file == nil || file.Filename == nil || file.Patch == nil {
+		if file == nil || file.Filename == nil {
 			continue
 		}
 
-		lineBounds, err := patchToLineBounds(*file.Patch)
+		// files may have no patch (e.g., renamed-only files), which the unpatched file policy applies to
+		internalFile, err := newPullRequestFile(*file.Filename, file.GetPreviousFilename(), file.GetStatus(), file.GetPatch())
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to generate line bounds for file %q", *file.Filename)
+			return nil, err
 		}
-
-		internalFiles = append(
-			internalFiles,
-			&pullRequestFile{
-				filename:   *file.Filename,
-				patch:      *file.Patch,
-				lineBounds: lineBounds,
-			},
-		)
+		internalFiles = append(internalFiles, internalFile)
 	}
 	return internalFiles, nil
 }
@@ -152,38 +388,117 @@ func patchToLineBounds(patch string) ([]lineBound, error) {
 
 	scanner := bufio.NewScanner(strings.NewReader(patch))
 	for scanner.Scan() {
-		line := scanner.Text()
-		// patch header lines are formatted like:
-		// @@ -0,0 +1,5 @@ <arbitrary line of code which may be blank>
-		// The second number in each pair (the offset) may not be set, e.g.:
-		// @@ -0,0 +1 @@ <arbitrary line of code which may be blank>
-		if len(line) >= 13 && strings.HasPrefix(line, "@@ -") && strings.Contains(line[2:], " @@") {
-			// split into four pieces: (0) @@, (1) old line number and offset, (2), new line number and offset, (3) @@
-			segments := strings.Split(line, " ")
-			if len(segments) < 4 {
-				continue
-			}
+		header, isHeader, err := parseHunkHeader(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if isHeader {
+			lineBounds = append(lineBounds, lineBound{start: header.newStart, end: header.newStart + header.newLength - 1})
+		}
+	}
 
-			// split and parse new line numbers
-			bounds := strings.Split(segments[2], ",")
-			start, err := strconv.Atoi(bounds[0][1:]) // drop the "+"
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to convert %v to integer while processing %q", bounds[0][1:], line)
-			}
-			var endOffset int
-			if len(bounds) == 1 { // endOffset may not exist (e.g., @@ -0,0 +1 @@)
-				endOffset = 1
-			} else {
-				var err error
-				endOffset, err = strconv.Atoi(bounds[1]) // one-indexed offset (subtract 1 when using)
-				if err != nil {
-					return nil, errors.Wrapf(err, "failed to convert %v to integer while processing %q", bounds[0][1:], line)
-				}
-			}
+	return lineBounds, nil
+}
+
+// List the new line numbers of the lines a patch adds (a modified line is a removal followed by an addition).
+func patchToAddedLines(patch string) ([]int, error) {
+	var addedLines []int
+
+	line := 0
+	inHunk := false
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	for scanner.Scan() {
+		text := scanner.Text()
+		header, isHeader, err := parseHunkHeader(text)
+		if err != nil {
+			return nil, err
+		}
+		if isHeader {
+			line, inHunk = header.newStart, true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
 
-			lineBounds = append(lineBounds, lineBound{start: start, end: start + endOffset - 1})
+		switch {
+		case strings.HasPrefix(text, "+"):
+			addedLines = append(addedLines, line)
+			line += 1
+		case strings.HasPrefix(text, "-"), strings.HasPrefix(text, "\\"): // removed lines, and "\ No newline at end of file"
+		default: // unchanged context lines
+			line += 1
 		}
 	}
 
-	return lineBounds, nil
+	return addedLines, nil
+}
+
+// The line ranges of a patch hunk, on the old and new sides of the patch.
+type hunkHeader struct {
+	oldStart, oldLength int
+	newStart, newLength int
+}
+
+// Parse the line ranges of a patch hunk header. Lines which are not hunk headers are reported with isHeader false.
+func parseHunkHeader(line string) (header hunkHeader, isHeader bool, err error) {
+	// patch header lines are formatted like:
+	// @@ -0,0 +1,5 @@ <arbitrary line of code which may be blank>
+	// The second number in each pair (the offset) may not be set, e.g.:
+	// @@ -0,0 +1 @@ <arbitrary line of code which may be blank>
+	if len(line) < 11 || !strings.HasPrefix(line, "@@ -") || !strings.Contains(line[2:], " @@") {
+		return hunkHeader{}, false, nil
+	}
+
+	// split into four pieces: (0) @@, (1) old line number and offset, (2), new line number and offset, (3) @@
+	segments := strings.Split(line, " ")
+	if len(segments) < 4 {
+		return hunkHeader{}, false, nil
+	}
+
+	header.oldStart, header.oldLength, err = parseHunkRange(segments[1], line)
+	if err != nil {
+		return hunkHeader{}, false, err
+	}
+	header.newStart, header.newLength, err = parseHunkRange(segments[2], line)
+	if err != nil {
+		return hunkHeader{}, false, err
+	}
+	return header, true, nil
+}
+
+// Parse one side of a hunk header (e.g., "+1,5"), whose length defaults to 1 when not set.
+func parseHunkRange(hunkRange string, line string) (start int, length int, err error) {
+	bounds := strings.Split(hunkRange, ",")
+	start, err = strconv.Atoi(bounds[0][1:]) // drop the "+" or "-"
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to convert %v to integer while processing %q", bounds[0][1:], line)
+	}
+	if len(bounds) == 1 { // the offset may not exist (e.g., @@ -0,0 +1 @@)
+		return start, 1, nil
+	}
+	length, err = strconv.Atoi(bounds[1]) // one-indexed offset (subtract 1 when using)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to convert %v to integer while processing %q", bounds[1], line)
+	}
+	return start, length, nil
+}
+
+// Widen each added line by context lines either side, merging overlapping or adjacent bounds.
+func addedLinesToLineBounds(addedLines []int, context int) []lineBound {
+	var lineBounds []lineBound
+	for _, line := range addedLines {
+		bound := lineBound{start: line - context, end: line + context}
+		if bound.start < 1 {
+			bound.start = 1
+		}
+		if last := len(lineBounds) - 1; last >= 0 && bound.start <= lineBounds[last].end+1 {
+			if bound.end > lineBounds[last].end {
+				lineBounds[last].end = bound.end
+			}
+			continue
+		}
+		lineBounds = append(lineBounds, bound)
+	}
+	return lineBounds
 }
@@ -9,9 +9,31 @@ import (
 	"github.com/pkg/errors"
 )
 
+type CheckRunConfiguration struct {
+	Name              string
+	FilterAnnotations bool
+	// Which lines of the diff annotations are kept on when filtering (defaults to whole hunks)
+	Filter                LineFilter
+	AnnotateStartLineOnly bool
+	// Post a notice annotation on each related location (and code flow step) which falls inside the diff
+	AnnotateRelatedLocations bool
+	ExistingCheckRuns        ExistingCheckRunPolicy
+	// A reference for the check run on the caller's system, used to match existing check runs when set
+	ExternalID string
+	// An existing check run (e.g., one started before the scan) to complete with the annotations
+	CheckRunID int64
+	Conclusion ConclusionPolicy
+	// A previous attempt to post the same annotations which failed part way through, to resume
+	Resume *PostingProgress
+	// Called with the progress before each page of annotations after the first is posted, and once every page has
+	// been posted, so that a failed attempt can be resumed
+	OnProgress func(PostingProgress) error
+}
+
 type PullRequestAnnotator struct {
-	client *github.Client
-	pr     *pullRequest
+	*CommitChecker
+	pr           *pullRequest
+	fileContents map[string][]string
 }
 
 func CreatePullRequestAnnotator(configuration ClientConfiguration, pullRequestConfiguration PullRequestConfiguration, headSHA string) (*PullRequestAnnotator, error) {
@@ -20,103 +42,220 @@ func CreatePullRequestAnnotator(configuration ClientConfiguration, pullRequestCo
 		return nil, errors.Wrap(err, "failed to create client")
 	}
 
-	pr, err := createPullRequest(client, pullRequestConfiguration.Owner, pullRequestConfiguration.Repo, pullRequestConfiguration.Number, headSHA)
+	pr, err := createPullRequest(client, pullRequestConfiguration, headSHA)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create pull request")
 	}
-	return &PullRequestAnnotator{client: client, pr: pr}, nil
+	checker := &CommitChecker{client: client, appID: configuration.AppID, usesToken: configuration.Token != "", owner: pr.owner, repo: pr.repo, headSHA: pr.headSHA}
+	return &PullRequestAnnotator{CommitChecker: checker, pr: pr}, nil
 }
 
-func computeConclusion(annotations []*Annotation) string {
-	// Can be one of "success", "failure", "neutral", "cancelled", "skipped", "timed_out", or "action_required".
-	conclusion := "success"
-
-	for _, annotation := range annotations {
-		switch annotation.level {
-		case failureLevel:
-			return "failure"
-		case warningLevel:
-			conclusion = "neutral"
+// Post annotations as a completed check run, returning its conclusion.
+func (annotator *PullRequestAnnotator) PostAnnotations(annotations []*Annotation, details CheckRunDetails, configuration CheckRunConfiguration) (string, error) {
+	checkName := configuration.Name
+	if annotator.pr.translation != nil {
+		var untranslatable []*Annotation
+		annotations, untranslatable = annotator.pr.translation.translateAnnotations(annotations)
+		for _, finding := range annotationsToFindings(untranslatable) {
+			finding.Reason = "its lines changed after the scanned commit"
+			details.UnannotatedFindings = append(details.UnannotatedFindings, finding)
 		}
+		details.TranslatedFromSHA = annotator.pr.translation.fromSHA
 	}
-	return conclusion
-}
 
-func (annotator *PullRequestAnnotator) PostAnnotations(annotations []*Annotation, checkName string, filterAnnotations bool, annotateStartLineOnly bool) error {
-	if filterAnnotations {
-		annotations = annotator.pr.filterAnnotations(annotations)
+	annotator.pr.followRenames(annotations)
+
+	var filteredAnnotations []*Annotation
+	if configuration.FilterAnnotations {
+		unfilteredAnnotations := annotations
+		annotations = annotator.pr.filterAnnotations(annotations, configuration.Filter)
+		filteredAnnotations = annotationsDifference(unfilteredAnnotations, annotations)
+	}
+	annotator.pr.addRawDetails(annotations)
+	details.MovedHeadSHA = annotator.pr.movedHeadSHA()
+	details.ComparisonTruncated = annotator.pr.comparisonTruncated
+	details.UntranslatableHeadSHA, details.UntranslatableReason = annotator.pr.untranslatableHeadSHA, annotator.pr.untranslatableReason
+	conclusion, conclusionReason := computeConclusion(annotations, configuration.Conclusion)
+	details.ConclusionReason = conclusionReason
+	if hasNoFindings(annotations, details) && configuration.Conclusion.NoFindings == NoFindingsNeutral {
+		conclusion = "neutral"
 	}
 
-	if annotateStartLineOnly {
+	summary, text := renderOutput(checkName, annotator.pr.headSHA, annotations, filteredAnnotations, details)
+
+	if configuration.AnnotateRelatedLocations {
+		// related locations are only useful within the diff, regardless of whether the findings themselves are filtered
+		annotations = append(annotations, annotator.pr.filterAnnotations(relatedLocationAnnotations(annotations), configuration.Filter)...)
+	}
+
+	if configuration.AnnotateStartLineOnly {
 		removeEndLines(annotations)
 	}
 
-	const MAX_ANNOTATIONS_PER_PAGE = 50
-	// When creating a check run you can add only 50 annotations - later annotations must be added via an update to the
-	// run. Split our annotations accordingly, and pull the github annotation off them.
-	chunkedGitHubAnnotations := [][]*github.CheckRunAnnotation{}
-	for i := 0; i < len(annotations); i += MAX_ANNOTATIONS_PER_PAGE {
-		chunkEnd := i + MAX_ANNOTATIONS_PER_PAGE
-		if chunkEnd > len(annotations) {
-			chunkEnd = len(annotations)
-		}
+	// a stable order means a resumed attempt posts the same pages
+	sortAnnotations(annotations)
+	pages := annotationPages(annotations)
+	fingerprint := annotationsFingerprint(annotations)
+
+	check_title := fmt.Sprintf("Findings for %s", checkName)
+	if hasNoFindings(annotations, details) {
+		check_title = fmt.Sprintf("No findings for %s", checkName)
+	}
+	var textPointer *string
+	if text != "" {
+		textPointer = &text
+	}
 
-		thisChunk := []*github.CheckRunAnnotation{}
-		for j := i; j < chunkEnd; j++ {
-			thisChunk = append(thisChunk, annotations[j].githubAnnotation)
+	totalPages := len(pages)
+	if totalPages == 0 {
+		totalPages = 1 // a check run without annotations is still posted
+	}
+	recordProgress := func(checkRunID int64, postedPages int) error {
+		if configuration.OnProgress == nil {
+			return nil
 		}
-		chunkedGitHubAnnotations = append(chunkedGitHubAnnotations, thisChunk)
+		progress := PostingProgress{HeadSHA: annotator.pr.headSHA, CheckName: checkName, CheckRunID: checkRunID, PostedPages: postedPages, Fingerprint: fingerprint}
+		return errors.Wrap(configuration.OnProgress(progress), "failed to record progress")
 	}
 
-	check_title := fmt.Sprintf("Findings for %s", checkName)
-	summary := fmt.Sprintf("A set of findings for %s on commit %s.", checkName, annotator.pr.headSHA)
+	// resume only an attempt which posted the same annotations to the same commit
+	resumed := configuration.Resume
+	if !resumed.resumes(annotator.pr.headSHA, checkName, fingerprint) {
+		resumed = nil
+	}
+	if resumed != nil && resumed.PostedPages >= totalPages {
+		// every annotation was posted by the previous attempt
+		return conclusion, nil
+	}
+	checkRunID := configuration.CheckRunID
+	if resumed != nil {
+		checkRunID = resumed.CheckRunID
+	}
 
-	var first_annotations []*github.CheckRunAnnotation = nil
-	if len(chunkedGitHubAnnotations) == 1 {
-		first_annotations = chunkedGitHubAnnotations[0]
-		chunkedGitHubAnnotations = nil
-	} else if (len(chunkedGitHubAnnotations)) > 1 {
-		first_annotations = chunkedGitHubAnnotations[0]
-		chunkedGitHubAnnotations = chunkedGitHubAnnotations[1:]
+	var existingCheckRuns []*github.CheckRun
+	if configuration.ExistingCheckRuns != CreateCheckRunPolicy && configuration.ExistingCheckRuns != "" {
+		var err error
+		existingCheckRuns, err = annotator.existingCheckRuns(checkName, configuration.ExternalID)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to find existing check runs")
+		}
 	}
+	reusedCheckRun, supersededCheckRuns := selectCheckRuns(configuration.ExistingCheckRuns, existingCheckRuns, checkRunID)
+
+	var checkRun *github.CheckRun
+	postedPages := 0
+	if resumed != nil {
+		checkRun = &github.CheckRun{ID: github.Int64(resumed.CheckRunID)}
+		postedPages = resumed.PostedPages
+	} else {
+		var first_annotations []*github.CheckRunAnnotation = nil
+		if len(pages) > 0 {
+			first_annotations = pages[0]
+		}
+		output := github.CheckRunOutput{
+			Title:   &check_title,
+			Summary: &summary,
+			Text:    textPointer,
 
-	output := github.CheckRunOutput{
-		Title:   &check_title,
-		Summary: &summary,
+			Annotations: first_annotations,
+		}
 
-		Annotations: first_annotations,
+		externalID := optionalString(annotator.externalID(configuration.ExternalID))
+		completed_at := github.Timestamp{Time: time.Now()}
+		var err error
+		if reusedCheckRun != nil {
+			options := github.UpdateCheckRunOptions{
+				Name:        checkName,
+				ExternalID:  externalID,
+				Status:      github.String("completed"),
+				Output:      &output,
+				Conclusion:  &conclusion,
+				CompletedAt: &completed_at,
+			}
+			checkRun, _, err = annotator.client.Checks.UpdateCheckRun(context.Background(), annotator.pr.owner, annotator.pr.repo, reusedCheckRun.GetID(), options)
+			if err != nil {
+				return "", errors.Wrapf(explainPermissionError(err, checksWritePermission), "failed to update check run %d", reusedCheckRun.GetID())
+			}
+		} else {
+			options := github.CreateCheckRunOptions{
+				Name:        checkName,
+				HeadSHA:     annotator.pr.headSHA,
+				ExternalID:  externalID,
+				Output:      &output,
+				Conclusion:  &conclusion,
+				CompletedAt: &completed_at,
+			}
+			checkRun, _, err = annotator.client.Checks.CreateCheckRun(context.Background(), annotator.pr.owner, annotator.pr.repo, options)
+			if err != nil {
+				return "", errors.Wrap(explainPermissionError(err, checksWritePermission), "failed to create check run")
+			}
+		}
+		postedPages = 1
 	}
 
-	conclusion := computeConclusion(annotations)
-	completed_at := github.Timestamp{Time: time.Now()}
-	options := github.CreateCheckRunOptions{
-		Name:        checkName,
-		HeadSHA:     annotator.pr.headSHA,
-		Output:      &output,
-		Conclusion:  &conclusion,
-		CompletedAt: &completed_at,
-	}
-	checkRun, _, err := annotator.client.Checks.CreateCheckRun(context.Background(), annotator.pr.owner, annotator.pr.repo, options)
-	if err != nil {
-		return errors.Wrap(err, "failed to create check run")
-	}
+	for ; postedPages < len(pages); postedPages++ {
+		if err := recordProgress(checkRun.GetID(), postedPages); err != nil {
+			return "", err
+		}
 
-	for i, annotationChunk := range chunkedGitHubAnnotations {
 		output := github.CheckRunOutput{
 			Title:   &check_title, // required, even though there is no update
 			Summary: &summary,     // required, even though there is no update
+			Text:    textPointer,
 
-			Annotations: annotationChunk, // new annotations are appended (not overwritten)
+			Annotations: pages[postedPages], // new annotations are appended (not overwritten)
 		}
 		options := github.UpdateCheckRunOptions{
 			Name:   checkName, // required, even though there is no update
 			Output: &output,
 		}
-		checkRun, _, err = annotator.client.Checks.UpdateCheckRun(context.Background(), annotator.pr.owner, annotator.pr.repo, checkRun.GetID(), options)
+		updatedCheckRun, _, err := annotator.client.Checks.UpdateCheckRun(context.Background(), annotator.pr.owner, annotator.pr.repo, checkRun.GetID(), options)
 		if err != nil {
-			return errors.Wrapf(err, "failed to post page %d of annotations - some posted successfully", i+2)
+			postErr := errors.Wrapf(err, "failed to post page %d of annotations", postedPages+1)
+			posted := postedPages * maxAnnotationsPerPage
+			return "", annotator.reportPartialPosting(checkRun.GetID(), checkName, check_title, summary, textPointer, posted, len(annotations), postErr)
+		}
+		checkRun = updatedCheckRun
+	}
+
+	for _, supersededCheckRun := range supersededCheckRuns {
+		if err := annotator.supersedeCheckRun(supersededCheckRun, checkRun); err != nil {
+			return "", errors.Wrap(err, "posted all annotations but failed to supersede existing check runs")
 		}
 	}
 
-	return nil
+	if err := recordProgress(checkRun.GetID(), totalPages); err != nil {
+		return "", err
+	}
+	return conclusion, nil
+}
+
+// Note on a check run that only some of its annotations were posted (so that its summary does not mislead), returning
+// the error which stopped the rest.
+func (annotator *PullRequestAnnotator) reportPartialPosting(checkRunID int64, checkName string, title string, summary string, text *string, posted int, total int, postErr error) error {
+	partialSummary := truncateMarkdown(fmt.Sprintf("**Only %d of %d annotations were posted**, as posting the rest failed.\n\n%s", posted, total, summary), maxOutputLength)
+	options := github.UpdateCheckRunOptions{
+		Name:   checkName,
+		Output: &github.CheckRunOutput{Title: &title, Summary: &partialSummary, Text: text},
+	}
+	if _, _, err := annotator.client.Checks.UpdateCheckRun(context.Background(), annotator.pr.owner, annotator.pr.repo, checkRunID, options); err != nil {
+		return errors.Wrapf(postErr, "%d of %d annotations were posted, and the check run's summary could not be updated to say so (%v)", posted, total, err)
+	}
+	return errors.Wrapf(postErr, "%d of %d annotations were posted", posted, total)
+}
+
+// Returns the annotations in all which are not in subset.
+func annotationsDifference(all []*Annotation, subset []*Annotation) []*Annotation {
+	inSubset := make(map[*Annotation]bool)
+	for _, annotation := range subset {
+		inSubset[annotation] = true
+	}
+
+	var difference []*Annotation
+	for _, annotation := range all {
+		if !inSubset[annotation] {
+			difference = append(difference, annotation)
+		}
+	}
+	return difference
 }
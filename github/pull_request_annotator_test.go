@@ -1,8 +1,16 @@
 package github
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/google/go-github/v47/github"
 )
 
 func TestComputeConclusion(t *testing.T) {
@@ -10,6 +18,7 @@ func TestComputeConclusion(t *testing.T) {
 	warningAnnotation := Annotation{level: warningLevel}
 	failureAnnotation := Annotation{level: failureLevel}
 	invalidAnnotation := Annotation{level: 4}
+	unchangedFailureAnnotation := Annotation{level: failureLevel, unchanged: true}
 
 	tests := []struct {
 		name        string
@@ -22,13 +31,148 @@ func TestComputeConclusion(t *testing.T) {
 		{"notice and warning and failure", []*Annotation{&noticeAnnotation, &warningAnnotation, &failureAnnotation}, "failure"},
 		{"notice and warning and failure reordered", []*Annotation{&failureAnnotation, &warningAnnotation, &noticeAnnotation}, "failure"},
 		{"warning and invalid", []*Annotation{&invalidAnnotation, &warningAnnotation}, "neutral"},
+		{"unchanged failure", []*Annotation{&unchangedFailureAnnotation, &noticeAnnotation}, "success"},
+		{"unchanged failure and warning", []*Annotation{&unchangedFailureAnnotation, &warningAnnotation}, "neutral"},
 	}
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("%s returns %s", tt.name, tt.conclusion), func(t *testing.T) {
-			got := computeConclusion(tt.annotations)
+			got, _ := computeConclusion(tt.annotations, ConclusionPolicy{})
 			if tt.conclusion != got {
 				t.Errorf("expected %q but got %q", tt.conclusion, got)
 			}
 		})
 	}
 }
+
+func TestAnnotationsDifference(t *testing.T) {
+	first := Annotation{fileName: "a"}
+	second := Annotation{fileName: "b"}
+	third := Annotation{fileName: "c"}
+
+	got := annotationsDifference([]*Annotation{&first, &second, &third}, []*Annotation{&second})
+	if len(got) != 2 || got[0] != &first || got[1] != &third {
+		t.Errorf("expected the first and third annotations but got %v", got)
+	}
+
+	if got := annotationsDifference([]*Annotation{&first}, []*Annotation{&first}); len(got) != 0 {
+		t.Errorf("expected no annotations but got %v", got)
+	}
+}
+
+// A fake of the check runs API, which fails the first request to append annotations.
+type fakeChecksServer struct {
+	*httptest.Server
+	created       int
+	failed        bool
+	updates       map[int64][]github.UpdateCheckRunOptions
+	existingRunID int64
+}
+
+func newFakeChecksServer(t *testing.T) *fakeChecksServer {
+	fake := &fakeChecksServer{updates: make(map[int64][]github.UpdateCheckRunOptions), existingRunID: 3}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/o/r/check-runs":
+			fake.created++
+			fmt.Fprint(w, `{"id": 7, "name": "semgrep"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/o/r/commits/abc123/check-runs":
+			checkRuns := []string{fmt.Sprintf(`{"id": %d, "name": "semgrep"}`, fake.existingRunID)}
+			if fake.created > 0 {
+				checkRuns = append(checkRuns, `{"id": 7, "name": "semgrep"}`)
+			}
+			fmt.Fprintf(w, `{"total_count": %d, "check_runs": [%s]}`, len(checkRuns), strings.Join(checkRuns, ","))
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/o/r/check-runs/"):
+			id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/repos/o/r/check-runs/"), 10, 64)
+			if err != nil {
+				t.Errorf("unexpected check run %q", r.URL.Path)
+			}
+			var options github.UpdateCheckRunOptions
+			if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+				t.Errorf("failed to decode update: %v", err)
+			}
+			if !fake.failed && len(options.Output.Annotations) > 0 {
+				fake.failed = true
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"message": "Validation Failed"}`)
+				return
+			}
+			fake.updates[id] = append(fake.updates[id], options)
+			fmt.Fprintf(w, `{"id": %d, "name": "semgrep"}`, id)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return fake
+}
+
+func TestPostAnnotationsResumesFailedAttempt(t *testing.T) {
+	fake := newFakeChecksServer(t)
+	defer fake.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(fake.URL + "/")
+	annotator := &PullRequestAnnotator{
+		CommitChecker: &CommitChecker{client: client, appID: 1, owner: "o", repo: "r", headSHA: "abc123"},
+		pr:            &pullRequest{owner: "o", repo: "r", headSHA: "abc123"},
+	}
+
+	// three pages of annotations
+	var annotations []*Annotation
+	for line := 1; line <= 2*maxAnnotationsPerPage+20; line++ {
+		annotation, err := CreateAnnotation("src/main.py", line, line, "warning", "rule", "message")
+		if err != nil {
+			t.Fatal(err)
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	var progress PostingProgress
+	configuration := CheckRunConfiguration{
+		Name:              "semgrep",
+		ExistingCheckRuns: SupersedeCheckRunPolicy,
+		OnProgress:        func(p PostingProgress) error { progress = p; return nil },
+	}
+	if _, err := annotator.PostAnnotations(annotations, CheckRunDetails{}, configuration); err == nil || !strings.Contains(err.Error(), "50 of 120 annotations were posted") {
+		t.Fatalf("expected posting page 2 to fail but received %v", err)
+	}
+	if progress.CheckRunID != 7 || progress.PostedPages != 1 {
+		t.Fatalf("expected page 1 of check run 7 to be recorded but got %+v", progress)
+	}
+	if updates := fake.updates[7]; len(updates) != 1 || !strings.HasPrefix(updates[0].Output.GetSummary(), "**Only 50 of 120 annotations were posted**") {
+		t.Errorf("expected the summary to note the partial posting but got %v", updates)
+	}
+	if len(fake.updates[fake.existingRunID]) != 0 {
+		t.Errorf("expected the existing check run not to be superseded before every page was posted")
+	}
+
+	fake.updates = make(map[int64][]github.UpdateCheckRunOptions)
+	resumed := progress
+	configuration.Resume = &resumed
+	conclusion, err := annotator.PostAnnotations(annotations, CheckRunDetails{}, configuration)
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	if conclusion != "neutral" {
+		t.Errorf("expected a neutral conclusion but got %q", conclusion)
+	}
+	if fake.created != 1 {
+		t.Errorf("expected the resumed attempt not to create a check run but %d were created", fake.created)
+	}
+	updates := fake.updates[7]
+	if len(updates) != 2 || len(updates[0].Output.Annotations) != maxAnnotationsPerPage || len(updates[1].Output.Annotations) != 20 {
+		t.Fatalf("expected pages 2 and 3 to be appended to check run 7 but got %d updates", len(updates))
+	}
+	for _, update := range updates {
+		if update.Conclusion != nil {
+			t.Errorf("expected the resumed check run not to be superseded but it was concluded %q", update.GetConclusion())
+		}
+	}
+	if superseded := fake.updates[fake.existingRunID]; len(superseded) != 1 || superseded[0].GetConclusion() != "skipped" {
+		t.Errorf("expected the existing check run to be superseded but got %v", superseded)
+	}
+	if progress.CheckRunID != 7 || progress.PostedPages != 3 {
+		t.Errorf("expected every page of check run 7 to be recorded but got %+v", progress)
+	}
+}
@@ -142,12 +142,12 @@ func TestFilterAnnotations(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tt.pr.filterAnnotations(tt.annotations)
+			got := tt.pr.filterAnnotations(tt.annotations, LineFilter{Granularity: HunkFilterGranularity})
 
 			for _, expectedAnnotation := range tt.filteredAnnotations {
 				found := false
 				for _, gotAnnotation := range got {
-					if *gotAnnotation == *expectedAnnotation {
+					if reflect.DeepEqual(gotAnnotation, expectedAnnotation) {
 						found = true
 						break
 					}
@@ -170,6 +170,7 @@ func TestSdkFilesToInternalFiles(t *testing.T) {
 +1
 +2`
 	bounds_1, _ := patchToLineBounds(patch_1)
+	added_1, _ := patchToAddedLines(patch_1)
 
 	filename_2 := "src/main_2.go"
 	patch_2 := `@@ -3,2 +3,2 @@ hi there
@@ -177,6 +178,7 @@ func TestSdkFilesToInternalFiles(t *testing.T) {
 +1
 +2`
 	bounds_2, _ := patchToLineBounds(patch_2)
+	added_2, _ := patchToAddedLines(patch_2)
 
 	github_file_no_patch := github.CommitFile{
 		Filename: &filename_1,
@@ -198,11 +200,16 @@ func TestSdkFilesToInternalFiles(t *testing.T) {
 		filename:   filename_1,
 		patch:      patch_1,
 		lineBounds: bounds_1,
+		addedLines: added_1,
+	}
+	internal_file_no_patch := pullRequestFile{
+		filename: filename_1,
 	}
 	internal_file_2 := pullRequestFile{
 		filename:   filename_2,
 		patch:      patch_2,
 		lineBounds: bounds_2,
+		addedLines: added_2,
 	}
 
 	tests := []struct {
@@ -228,7 +235,7 @@ func TestSdkFilesToInternalFiles(t *testing.T) {
 		{
 			"one file without patch",
 			[]*github.CommitFile{&github_file_no_patch},
-			[]*pullRequestFile{},
+			[]*pullRequestFile{&internal_file_no_patch},
 		},
 		{
 			"one file without name",
@@ -236,9 +243,9 @@ func TestSdkFilesToInternalFiles(t *testing.T) {
 			[]*pullRequestFile{},
 		},
 		{
-			"four files, two of which are valid",
+			"four files, three of which are valid",
 			[]*github.CommitFile{&github_file_1, &github_file_2, &github_file_no_name, &github_file_no_patch},
-			[]*pullRequestFile{&internal_file_1, &internal_file_2},
+			[]*pullRequestFile{&internal_file_1, &internal_file_2, &internal_file_no_patch},
 		},
 	}
 	for _, tt := range tests {
@@ -286,6 +293,12 @@ func TestPatchToLineBounds(t *testing.T) {
 @@ -0,0 +1 @@
 +1
 `, []lineBound{{1, 1}},
+		},
+		{"one-line change with no offsets", `
+@@ -5 +5 @@
+-old
++new
+`, []lineBound{{5, 5}},
 		},
 		{"removed file", `
 @@ -1,224 +1 @@
@@ -344,3 +357,185 @@ def foo():
 		})
 	}
 }
+
+func TestPatchToAddedLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		patch      string
+		addedLines []int
+	}{
+		{"empty patch", "", nil},
+		{"new file", "@@ -0,0 +1,3 @@\n+a\n+b\n+c", []int{1, 2, 3}},
+		{"removed lines only", "@@ -4,3 +4,1 @@\n-a\n-b\n c", nil},
+		{"modified line between context", "@@ -4,7 +4,7 @@\n a\n b\n c\n-d\n+D\n e\n f\n g", []int{7}},
+		{"multiple hunks", "@@ -1,2 +1,3 @@\n a\n+b\n c\n@@ -20,2 +21,3 @@ def f():\n x\n+y\n\\ No newline at end of file\n", []int{2, 22}},
+		{"blank context line", "@@ -1,3 +1,4 @@\n a\n\n+b\n c", []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchToAddedLines(tt.patch)
+			if err != nil {
+				t.Errorf("expected no error but received %q", err)
+			}
+			if !reflect.DeepEqual(got, tt.addedLines) {
+				t.Errorf("expected added lines %v but got %v", tt.addedLines, got)
+			}
+		})
+	}
+}
+
+func TestAddedLinesToLineBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		addedLines []int
+		context    int
+		lineBounds []lineBound
+	}{
+		{"no added lines", nil, 3, nil},
+		{"no context", []int{2, 3, 7}, 0, []lineBound{{2, 3}, {7, 7}}},
+		{"context merges nearby lines", []int{2, 7, 20}, 2, []lineBound{{1, 9}, {18, 22}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addedLinesToLineBounds(tt.addedLines, tt.context); !reflect.DeepEqual(got, tt.lineBounds) {
+				t.Errorf("expected %s but got %s", lineBoundsString(tt.lineBounds), lineBoundsString(got))
+			}
+		})
+	}
+}
+
+func TestFilterAnnotationsByGranularity(t *testing.T) {
+	file, err := newPullRequestFile("src/main.py", "", "modified", "@@ -4,7 +4,7 @@\n a\n b\n c\n-d\n+D\n e\n f\n g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := pullRequest{files: []*pullRequestFile{file}}
+
+	onAddedLine := &Annotation{fileName: "src/main.py", startLine: 7, endLine: 7}
+	nearAddedLine := &Annotation{fileName: "src/main.py", startLine: 9, endLine: 9}
+	onContextLine := &Annotation{fileName: "src/main.py", startLine: 4, endLine: 4}
+	outsideHunk := &Annotation{fileName: "src/main.py", startLine: 40, endLine: 40}
+	otherFile := &Annotation{fileName: "src/other.py", startLine: 7, endLine: 7}
+	annotations := []*Annotation{onAddedLine, nearAddedLine, onContextLine, outsideHunk, otherFile}
+
+	tests := []struct {
+		name     string
+		filter   LineFilter
+		expected []*Annotation
+	}{
+		{"file", LineFilter{Granularity: FileFilterGranularity}, []*Annotation{onAddedLine, nearAddedLine, onContextLine, outsideHunk}},
+		{"hunk", LineFilter{Granularity: HunkFilterGranularity}, []*Annotation{onAddedLine, nearAddedLine, onContextLine}},
+		{"default", LineFilter{}, []*Annotation{onAddedLine, nearAddedLine, onContextLine}},
+		{"added", LineFilter{Granularity: AddedLinesFilterGranularity}, []*Annotation{onAddedLine}},
+		{"added with context", LineFilter{Granularity: AddedLinesFilterGranularity, AddedLineContext: 2}, []*Annotation{onAddedLine, nearAddedLine}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pr.filterAnnotations(annotations, tt.filter); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseFilterGranularity(t *testing.T) {
+	for _, valid := range []string{"file", "hunk", "added"} {
+		if got, err := ParseFilterGranularity(valid); err != nil || string(got) != valid {
+			t.Errorf("expected %q to parse but got %q (%v)", valid, got, err)
+		}
+	}
+	if _, err := ParseFilterGranularity("lines"); err == nil {
+		t.Error("expected an error for \"lines\" but received none")
+	}
+}
+
+func TestParseDiffSource(t *testing.T) {
+	for _, valid := range []string{"compare", "files", "git"} {
+		if got, err := ParseDiffSource(valid); err != nil || string(got) != valid {
+			t.Errorf("expected %q to parse but got %q (%v)", valid, got, err)
+		}
+	}
+	if _, err := ParseDiffSource("api"); err == nil {
+		t.Error("expected an error for \"api\" but received none")
+	}
+}
+
+func TestMovedHeadSHA(t *testing.T) {
+	tests := []struct {
+		name, headSHA, latestHeadSHA, expected string
+	}{
+		{"head unchanged", "abc123", "abc123", ""},
+		{"head moved", "abc123", "def456", "def456"},
+		{"head unknown", "abc123", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := pullRequest{headSHA: tt.headSHA, details: &github.PullRequest{Head: &github.PullRequestBranch{SHA: &tt.latestHeadSHA}}}
+			if got := pr.movedHeadSHA(); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFollowRenames(t *testing.T) {
+	pr := pullRequest{files: []*pullRequestFile{
+		{filename: "src/new.py", previousFilename: "src/old.py", status: "renamed"},
+		{filename: "src/copy.py", previousFilename: "src/original.py", status: "copied"},
+		{filename: "src/reused.py", previousFilename: "src/gone.py", status: "renamed"},
+		{filename: "src/gone.py", status: "added"},
+	}}
+
+	renamed, _ := CreateAnnotation("src/old.py", 3, 4, "warning", "rule", "message")
+	renamed.AddRelatedLocations(AnnotationLocation{Path: "src/old.py", StartLine: 1, EndLine: 1})
+	renamed.AddCodeFlows(AnnotationCodeFlow{Steps: []AnnotationLocation{{Path: "src/old.py", StartLine: 2, EndLine: 2}}})
+	copied, _ := CreateAnnotation("src/original.py", 1, 1, "warning", "rule", "message")
+	recreated, _ := CreateAnnotation("src/gone.py", 1, 1, "warning", "rule", "message")
+
+	pr.followRenames([]*Annotation{renamed, copied, recreated})
+
+	if renamed.fileName != "src/new.py" || *renamed.githubAnnotation.Path != "src/new.py" || renamed.startLine != 3 || renamed.endLine != 4 {
+		t.Errorf("expected the annotation to move to src/new.py:3-4 but got %v", renamed)
+	}
+	if renamed.relatedLocations[0].Path != "src/new.py" || renamed.codeFlows[0].Steps[0].Path != "src/new.py" {
+		t.Errorf("expected related locations to move to src/new.py but got %v and %v", renamed.relatedLocations, renamed.codeFlows)
+	}
+	if copied.fileName != "src/original.py" {
+		t.Errorf("expected an annotation on a copied file's original to stay put but got %v", copied)
+	}
+	if recreated.fileName != "src/gone.py" {
+		t.Errorf("expected an annotation on a file which exists on the head to stay put but got %v", recreated)
+	}
+}
+
+func TestFilterAnnotationsUnpatchedFiles(t *testing.T) {
+	pr := pullRequest{files: []*pullRequestFile{
+		{filename: "src/renamed.py", previousFilename: "src/old.py", status: "renamed"},
+		{filename: "src/added.py", status: "added"},
+		{filename: "src/large.py", status: "modified"},
+	}}
+
+	renamed := &Annotation{fileName: "src/renamed.py", startLine: 40, endLine: 40}
+	added := &Annotation{fileName: "src/added.py", startLine: 1, endLine: 1}
+	large := &Annotation{fileName: "src/large.py", startLine: 1, endLine: 1}
+	annotations := []*Annotation{renamed, added, large}
+
+	if got := pr.filterAnnotations(annotations, LineFilter{UnpatchedFiles: SkipUnpatchedFilePolicy}); len(got) != 0 {
+		t.Errorf("expected no annotations but got %v", got)
+	}
+	expected := []*Annotation{renamed, added}
+	if got := pr.filterAnnotations(annotations, LineFilter{UnpatchedFiles: WholeFileUnpatchedFilePolicy}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+}
+
+func TestParseUnpatchedFilePolicy(t *testing.T) {
+	for _, valid := range []string{"skip", "whole_file"} {
+		if got, err := ParseUnpatchedFilePolicy(valid); err != nil || string(got) != valid {
+			t.Errorf("expected %q to parse but got %q (%v)", valid, got, err)
+		}
+	}
+	if _, err := ParseUnpatchedFilePolicy("whole"); err == nil {
+		t.Error("expected an error for \"whole\" but received none")
+	}
+}
@@ -0,0 +1,116 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GitHub rejects annotations whose raw details exceed 64 kilobytes.
+const maxRawDetailsLength = 64 * 1024
+
+func (pr *pullRequest) repositoryURL() string {
+	if url := pr.details.GetBase().GetRepo().GetHTMLURL(); url != "" {
+		return url
+	}
+	return fmt.Sprintf("https://github.com/%s/%s", pr.owner, pr.repo)
+}
+
+// Link to the given lines of a file at the head commit of the pull request.
+func (pr *pullRequest) locationURL(location AnnotationLocation) string {
+	url := fmt.Sprintf("%s/blob/%s/%s#L%d", pr.repositoryURL(), pr.headSHA, location.Path, location.StartLine)
+	if location.EndLine > location.StartLine {
+		url = fmt.Sprintf("%s-L%d", url, location.EndLine)
+	}
+	return url
+}
+
+// Render the code flows and related locations of each annotation into its raw details.
+func (pr *pullRequest) addRawDetails(annotations []*Annotation) {
+	for _, annotation := range annotations {
+		if len(annotation.codeFlows) == 0 && len(annotation.relatedLocations) == 0 {
+			continue
+		}
+		rawDetails := pr.renderRawDetails(annotation)
+		if len(rawDetails) > maxRawDetailsLength {
+			const truncationNotice = "\n... (truncated)"
+			rawDetails = truncateUTF8(rawDetails, maxRawDetailsLength-len(truncationNotice)) + truncationNotice
+		}
+		annotation.githubAnnotation.RawDetails = &rawDetails
+	}
+}
+
+func (pr *pullRequest) renderRawDetails(annotation *Annotation) string {
+	var sections []string
+	for i, codeFlow := range annotation.codeFlows {
+		var section strings.Builder
+		fmt.Fprintf(&section, "Code flow %d", i+1)
+		if codeFlow.Message != "" {
+			fmt.Fprintf(&section, ": %s", codeFlow.Message)
+		}
+		for j, step := range codeFlow.Steps {
+			fmt.Fprintf(&section, "\n  %d. %s\n     %s", j+1, locationAsString(step), pr.locationURL(step))
+		}
+		sections = append(sections, section.String())
+	}
+
+	if len(annotation.relatedLocations) > 0 {
+		var section strings.Builder
+		section.WriteString("Related locations")
+		for _, location := range annotation.relatedLocations {
+			fmt.Fprintf(&section, "\n  - %s\n    %s", locationAsString(location), pr.locationURL(location))
+		}
+		sections = append(sections, section.String())
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+func locationAsString(location AnnotationLocation) string {
+	description := fmt.Sprintf("%s:%d", location.Path, location.StartLine)
+	if location.EndLine > location.StartLine {
+		description = fmt.Sprintf("%s-%d", description, location.EndLine)
+	}
+	if message := strings.TrimSpace(location.Message); message != "" {
+		description = fmt.Sprintf("%s - %s", description, strings.ReplaceAll(message, "\n", " "))
+	}
+	return description
+}
+
+// Create a notice annotation for each related location and code flow step of the given annotations, skipping
+// locations which coincide with the annotation itself.
+func relatedLocationAnnotations(annotations []*Annotation) []*Annotation {
+	seen := make(map[[16]byte]bool)
+	var relatedAnnotations []*Annotation
+	for _, annotation := range annotations {
+		locations := append([]AnnotationLocation{}, annotation.relatedLocations...)
+		for _, codeFlow := range annotation.codeFlows {
+			locations = append(locations, codeFlow.Steps...)
+		}
+
+		for _, location := range locations {
+			endLine := location.EndLine
+			if endLine < location.StartLine {
+				endLine = location.StartLine
+			}
+			if location.Path == annotation.fileName && location.StartLine == annotation.startLine && endLine == annotation.endLine {
+				continue
+			}
+
+			message := strings.TrimSpace(location.Message)
+			if message == "" {
+				message = fmt.Sprintf("Related to the finding at %s:%d.", annotation.fileName, annotation.startLine)
+			}
+			title := fmt.Sprintf("%s (related location)", *annotation.githubAnnotation.Title)
+
+			relatedAnnotation, err := CreateAnnotation(location.Path, location.StartLine, endLine, "note", title, message)
+			if err != nil {
+				continue
+			}
+			if hash := relatedAnnotation.Hash(); !seen[hash] {
+				seen[hash] = true
+				relatedAnnotations = append(relatedAnnotations, relatedAnnotation)
+			}
+		}
+	}
+	return relatedAnnotations
+}
@@ -0,0 +1,99 @@
+package github
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v47/github"
+)
+
+func TestLocationURL(t *testing.T) {
+	pr := pullRequest{owner: "eliblock", repo: "less-advanced-security", headSHA: "abc123"}
+	enterprisePr := pullRequest{
+		owner:   "eliblock",
+		repo:    "less-advanced-security",
+		headSHA: "abc123",
+		details: &github.PullRequest{Base: &github.PullRequestBranch{Repo: &github.Repository{HTMLURL: github.String("https://ghe.example.com/eliblock/less-advanced-security")}}},
+	}
+
+	tests := []struct {
+		name     string
+		pr       pullRequest
+		location AnnotationLocation
+		url      string
+	}{
+		{"one line", pr, AnnotationLocation{Path: "src/main.py", StartLine: 4, EndLine: 4}, "https://github.com/eliblock/less-advanced-security/blob/abc123/src/main.py#L4"},
+		{"multiple lines", pr, AnnotationLocation{Path: "src/main.py", StartLine: 4, EndLine: 8}, "https://github.com/eliblock/less-advanced-security/blob/abc123/src/main.py#L4-L8"},
+		{"repository url from details", enterprisePr, AnnotationLocation{Path: "src/main.py", StartLine: 4}, "https://ghe.example.com/eliblock/less-advanced-security/blob/abc123/src/main.py#L4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pr.locationURL(tt.location); got != tt.url {
+				t.Errorf("expected %q but got %q", tt.url, got)
+			}
+		})
+	}
+}
+
+func TestAddRawDetails(t *testing.T) {
+	pr := pullRequest{owner: "o", repo: "r", headSHA: "sha"}
+
+	plain, _ := CreateAnnotation("src/sink.py", 20, 20, "error", "sqli", "tainted query")
+	withFlow, _ := CreateAnnotation("src/sink.py", 20, 20, "error", "sqli", "tainted query")
+	withFlow.AddCodeFlows(AnnotationCodeFlow{
+		Message: "user input reaches query",
+		Steps: []AnnotationLocation{
+			{Path: "src/source.py", StartLine: 3, EndLine: 3, Message: "request.args"},
+			{Path: "src/sink.py", StartLine: 20, EndLine: 21},
+		},
+	})
+	withFlow.AddRelatedLocations(AnnotationLocation{Path: "src/db.py", StartLine: 7, EndLine: 7, Message: "query built\nhere"})
+
+	pr.addRawDetails([]*Annotation{plain, withFlow})
+
+	if plain.githubAnnotation.RawDetails != nil {
+		t.Errorf("expected no raw details but got %q", *plain.githubAnnotation.RawDetails)
+	}
+
+	expected := strings.Join([]string{
+		"Code flow 1: user input reaches query",
+		"  1. src/source.py:3 - request.args",
+		"     https://github.com/o/r/blob/sha/src/source.py#L3",
+		"  2. src/sink.py:20-21",
+		"     https://github.com/o/r/blob/sha/src/sink.py#L20-L21",
+		"",
+		"Related locations",
+		"  - src/db.py:7 - query built here",
+		"    https://github.com/o/r/blob/sha/src/db.py#L7",
+	}, "\n")
+	if withFlow.githubAnnotation.RawDetails == nil || *withFlow.githubAnnotation.RawDetails != expected {
+		t.Errorf("expected raw details %q but got %v", expected, withFlow.githubAnnotation.RawDetails)
+	}
+}
+
+func TestRelatedLocationAnnotations(t *testing.T) {
+	annotation, _ := CreateAnnotation("src/sink.py", 20, 20, "error", "sqli", "tainted query")
+	annotation.AddCodeFlows(AnnotationCodeFlow{
+		Steps: []AnnotationLocation{
+			{Path: "src/source.py", StartLine: 3, EndLine: 3, Message: "request.args"},
+			{Path: "src/sink.py", StartLine: 20, EndLine: 20},
+		},
+	})
+	annotation.AddRelatedLocations(
+		AnnotationLocation{Path: "src/source.py", StartLine: 3, EndLine: 3, Message: "request.args"},
+		AnnotationLocation{Path: "src/db.py", StartLine: 7},
+	)
+
+	got := relatedLocationAnnotations([]*Annotation{annotation})
+
+	expectedSource, _ := CreateAnnotation("src/source.py", 3, 3, "note", "sqli (related location)", "request.args")
+	expectedDb, _ := CreateAnnotation("src/db.py", 7, 7, "note", "sqli (related location)", "Related to the finding at src/sink.py:20.")
+	expected := []*Annotation{expectedSource, expectedDb}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d annotations but got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		compareAnnotation(t, expected[i], got[i])
+	}
+}
@@ -0,0 +1,35 @@
+package github
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/v47/github"
+	"github.com/pkg/errors"
+)
+
+// Read a file from a repository at a commit (e.g., configuration at the pull request's head), returning nil when the
+// file does not exist.
+func ReadRepositoryFile(configuration ClientConfiguration, owner string, repo string, ref string, path string) ([]byte, error) {
+	client, err := createClient(configuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create client")
+	}
+
+	file, _, response, err := client.Repositories.GetContents(context.Background(), owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(explainPermissionError(err, "contents: read"), "failed to read %q at %s", path, ref)
+	}
+	if file == nil {
+		return nil, errors.Errorf("%q at %s is a directory", path, ref)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %q at %s", path, ref)
+	}
+	return []byte(content), nil
+}
@@ -0,0 +1,200 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 5
+	// the first backoff (which doubles with each retry, plus jitter) and the longest
+	baseRetryDelay = time.Second
+	maxRetryDelay  = 30 * time.Second
+	// the longest wait for a rate limit to reset before giving up
+	maxRateLimitWait = 5 * time.Minute
+)
+
+// Counts the requests made to the GitHub API and tracks the remaining rate limit, for a report at the end of a run.
+// Safe for use by several clients at once.
+type APIStats struct {
+	mutex                    sync.Mutex
+	requests, retries        int
+	rateLimit, rateRemaining int
+	rateReset                time.Time
+	hasRateLimit             bool
+}
+
+func (stats *APIStats) record(response *http.Response, retried bool) {
+	if stats == nil {
+		return
+	}
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.requests += 1
+	if retried {
+		stats.retries += 1
+	}
+	if response == nil {
+		return
+	}
+	limit, limitErr := strconv.Atoi(response.Header.Get("X-RateLimit-Limit"))
+	remaining, remainingErr := strconv.Atoi(response.Header.Get("X-RateLimit-Remaining"))
+	if limitErr == nil && remainingErr == nil {
+		stats.rateLimit, stats.rateRemaining, stats.hasRateLimit = limit, remaining, true
+		if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			stats.rateReset = time.Unix(reset, 0)
+		}
+	}
+}
+
+func (stats *APIStats) String() string {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	report := fmt.Sprintf("Made %d GitHub API requests (%d retries).", stats.requests, stats.retries)
+	if stats.hasRateLimit {
+		report += fmt.Sprintf(" %d of %d requests remain in the rate limit, which resets at %s.", stats.rateRemaining, stats.rateLimit, stats.rateReset.UTC().Format(time.RFC3339))
+	}
+	return report
+}
+
+// Retries requests which failed transiently: network errors and server errors (for requests which are safe to repeat),
+// and (secondary) rate limits. Waits honor
+// the Retry-After and X-RateLimit-Reset headers when present, and otherwise back off exponentially with jitter.
+// Retries stop at the request's context deadline, or once the next attempt would start after the transport's deadline.
+type retryTransport struct {
+	transport  http.RoundTripper
+	maxRetries int
+	// no retry starts after this time, when set
+	deadline time.Time
+	stats    *APIStats
+	// waits for a delay, returning early with an error when the context is done
+	sleep func(ctx context.Context, delay time.Duration) error
+}
+
+func newRetryTransport(transport http.RoundTripper, deadline time.Time, stats *APIStats) *retryTransport {
+	return &retryTransport{transport: transport, maxRetries: defaultMaxRetries, deadline: deadline, stats: stats, sleep: sleepContext}
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		attemptRequest := request
+		if attempt > 0 && request.Body != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest = request.Clone(request.Context())
+			attemptRequest.Body = body
+		}
+
+		response, err := t.transport.RoundTrip(attemptRequest)
+		t.stats.record(response, attempt > 0)
+
+		delay, retry := retryDelay(request.Method, response, err, attempt)
+		if !retry || attempt >= t.maxRetries || !t.canWait(request, delay) {
+			return response, err
+		}
+		if response != nil {
+			// the response is discarded, so its connection can be reused
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		if sleepErr := t.sleep(request.Context(), delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+// Whether a request can wait for a delay and be retried: its body must be replayable, and the wait must end before
+// any deadline.
+func (t *retryTransport) canWait(request *http.Request, delay time.Duration) bool {
+	if request.Body != nil && request.GetBody == nil {
+		return false
+	}
+	retryAt := time.Now().Add(delay)
+	if deadline, ok := request.Context().Deadline(); ok && retryAt.After(deadline) {
+		return false
+	}
+	return t.deadline.IsZero() || !retryAt.After(t.deadline)
+}
+
+// Whether a response (or error) is worth retrying, and how long to wait before the retry. A request which changes
+// something (e.g., creating a check run, or appending annotations to one) may have been applied before a server or
+// network error, so is only retried when rate limited, which proves it was not.
+func retryDelay(method string, response *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		// a context which is done will not succeed on retry
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || !isIdempotent(method) {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	rateLimited := isRateLimited(response)
+	if !rateLimited && (response.StatusCode < http.StatusInternalServerError || !isIdempotent(method)) {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(strings.TrimSpace(response.Header.Get("Retry-After"))); err == nil && seconds >= 0 {
+		delay := time.Duration(seconds) * time.Second
+		return delay, delay <= maxRateLimitWait
+	}
+	if rateLimited && response.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			delay := time.Until(time.Unix(reset, 0)) + time.Second
+			if delay > maxRateLimitWait {
+				return 0, false
+			}
+			if delay < 0 {
+				delay = 0
+			}
+			return delay, true
+		}
+	}
+	return backoff(attempt), true
+}
+
+// Whether GitHub refused a request under its primary or secondary rate limit, without processing it.
+func isRateLimited(response *http.Response) bool {
+	return response.StatusCode == http.StatusTooManyRequests ||
+		(response.StatusCode == http.StatusForbidden && (response.Header.Get("Retry-After") != "" || response.Header.Get("X-RateLimit-Remaining") == "0"))
+}
+
+// Whether repeating a request has the same effect as making it once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// An exponential backoff with full jitter.
+func backoff(attempt int) time.Duration {
+	delay := baseRetryDelay << attempt
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay))) + baseRetryDelay/2
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
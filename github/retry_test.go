@@ -0,0 +1,149 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Returns each response (or error) in turn, recording the bodies of the requests it receives.
+type fakeTransport struct {
+	responses []*http.Response
+	errs      []error
+	bodies    []string
+}
+
+func (t *fakeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		body, _ = io.ReadAll(request.Body)
+	}
+	t.bodies = append(t.bodies, string(body))
+	call := len(t.bodies) - 1
+	return t.responses[call], t.errs[call]
+}
+
+func fakeResponse(status int, headers map[string]string) *http.Response {
+	response := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(nil))}
+	for key, value := range headers {
+		response.Header.Set(key, value)
+	}
+	return response
+}
+
+func TestRetryTransport(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(10*time.Second).Unix(), 10)
+	tests := []struct {
+		name           string
+		method         string
+		responses      []*http.Response
+		errs           []error
+		deadline       time.Time
+		expectedStatus int // 0 when the error is returned
+		expectedDelays []time.Duration
+	}{
+		{"success", http.MethodPost, []*http.Response{fakeResponse(200, nil)}, []error{nil}, time.Time{}, 200, nil},
+		{"not found", http.MethodGet, []*http.Response{fakeResponse(404, nil)}, []error{nil}, time.Time{}, 404, nil},
+		{"forbidden", http.MethodGet, []*http.Response{fakeResponse(403, map[string]string{"X-RateLimit-Remaining": "4000"})}, []error{nil}, time.Time{}, 403, nil},
+		{"server error", http.MethodGet, []*http.Response{fakeResponse(502, nil), fakeResponse(200, nil)}, []error{nil, nil}, time.Time{}, 200, []time.Duration{-1}},
+		{"server error creating", http.MethodPost, []*http.Response{fakeResponse(502, nil)}, []error{nil}, time.Time{}, 502, nil},
+		{"server error updating", http.MethodPatch, []*http.Response{fakeResponse(504, nil)}, []error{nil}, time.Time{}, 504, nil},
+		{"network error", http.MethodGet, []*http.Response{nil, fakeResponse(200, nil)}, []error{errors.New("connection reset"), nil}, time.Time{}, 200, []time.Duration{-1}},
+		{"network error creating", http.MethodPost, []*http.Response{nil}, []error{errors.New("connection reset")}, time.Time{}, 0, nil},
+		{"secondary rate limit", http.MethodPost, []*http.Response{fakeResponse(403, map[string]string{"Retry-After": "3"}), fakeResponse(201, nil)}, []error{nil, nil}, time.Time{}, 201, []time.Duration{3 * time.Second}},
+		{"too many requests", http.MethodPatch, []*http.Response{fakeResponse(429, map[string]string{"Retry-After": "0"}), fakeResponse(200, nil)}, []error{nil, nil}, time.Time{}, 200, []time.Duration{0}},
+		{"primary rate limit", http.MethodPost, []*http.Response{fakeResponse(403, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}), fakeResponse(200, nil)}, []error{nil, nil}, time.Time{}, 200, []time.Duration{-11 * time.Second}},
+		{"rate limit beyond the longest wait", http.MethodPost, []*http.Response{fakeResponse(403, map[string]string{"Retry-After": "3600"})}, []error{nil}, time.Time{}, 403, nil},
+		{"past the deadline", http.MethodGet, []*http.Response{fakeResponse(503, nil)}, []error{nil}, time.Now().Add(-time.Second), 503, nil},
+		{
+			"retries exhausted",
+			http.MethodGet,
+			[]*http.Response{fakeResponse(500, nil), fakeResponse(500, nil), fakeResponse(500, nil), fakeResponse(500, nil), fakeResponse(500, nil), fakeResponse(500, nil)},
+			[]error{nil, nil, nil, nil, nil, nil},
+			time.Time{},
+			500,
+			[]time.Duration{-1, -1, -1, -1, -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTransport{responses: tt.responses, errs: tt.errs}
+			stats := &APIStats{}
+			var delays []time.Duration
+			transport := newRetryTransport(fake, tt.deadline, stats)
+			transport.sleep = func(ctx context.Context, delay time.Duration) error {
+				delays = append(delays, delay)
+				return nil
+			}
+
+			request, _ := http.NewRequest(tt.method, "https://api.github.com/repos/o/r/check-runs", bytes.NewBufferString(`{"name":"semgrep"}`))
+			response, err := transport.RoundTrip(request)
+			if tt.expectedStatus == 0 {
+				if err == nil {
+					t.Fatalf("expected an error but got %v", response)
+				}
+			} else if err != nil || response.StatusCode != tt.expectedStatus {
+				t.Fatalf("expected status %d but got %v (%v)", tt.expectedStatus, response, err)
+			}
+			if len(delays) != len(tt.expectedDelays) {
+				t.Fatalf("expected %d retries but got %v", len(tt.expectedDelays), delays)
+			}
+			for i, expected := range tt.expectedDelays {
+				switch {
+				case expected == -1: // a backoff with jitter
+					if delays[i] < baseRetryDelay/2 || delays[i] > maxRetryDelay+baseRetryDelay/2 {
+						t.Errorf("expected a backoff but waited %s", delays[i])
+					}
+				case expected < 0: // until a reset, allowing for the time taken by the test
+					if delays[i] > -expected || delays[i] < -expected-2*time.Second {
+						t.Errorf("expected to wait about %s but waited %s", -expected, delays[i])
+					}
+				default:
+					if delays[i] != expected {
+						t.Errorf("expected to wait %s but waited %s", expected, delays[i])
+					}
+				}
+			}
+			for _, body := range fake.bodies {
+				if body != `{"name":"semgrep"}` {
+					t.Errorf("expected the request body on every attempt but got %q", body)
+				}
+			}
+			if stats.requests != len(delays)+1 || stats.retries != len(delays) {
+				t.Errorf("expected %d requests and %d retries but got %d and %d", len(delays)+1, len(delays), stats.requests, stats.retries)
+			}
+		})
+	}
+}
+
+func TestRetryTransportCancelled(t *testing.T) {
+	fake := &fakeTransport{responses: []*http.Response{fakeResponse(502, nil)}, errs: []error{nil}}
+	transport := newRetryTransport(fake, time.Time{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/", nil)
+	if _, err := transport.RoundTrip(request); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to be cancelled but got %v", err)
+	}
+}
+
+func TestAPIStatsString(t *testing.T) {
+	stats := &APIStats{}
+	stats.record(nil, false)
+	if got := stats.String(); got != "Made 1 GitHub API requests (0 retries)." {
+		t.Errorf("unexpected report %q", got)
+	}
+
+	stats.record(fakeResponse(200, map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4990", "X-RateLimit-Reset": "1700000000"}), true)
+	expected := "Made 2 GitHub API requests (1 retries). 4990 of 5000 requests remain in the rate limit, which resets at 2023-11-14T22:13:20Z."
+	if got := stats.String(); got != expected {
+		t.Errorf("expected %q but got %q", expected, got)
+	}
+}
@@ -0,0 +1,254 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v47/github"
+	"github.com/pkg/errors"
+)
+
+// A Suggestion is a proposed change to a file (e.g., a sarif fix), posted as a pull request review comment containing
+// a suggested change which can be applied with one click.
+type Suggestion struct {
+	Path                        string
+	Title, Message, Description string
+	Replacements                []SuggestionReplacement
+}
+
+// A SuggestionReplacement deletes a region of a file and inserts Text in its place. Lines and columns are one-indexed
+// and the end column is exclusive. A zero start column starts the region at the beginning of its line, and a zero end
+// column ends the region at the end of its line.
+type SuggestionReplacement struct {
+	StartLine, StartColumn, EndLine, EndColumn int
+	Text                                       string
+}
+
+func (suggestion *Suggestion) lineRange() (startLine int, endLine int) {
+	for i, replacement := range suggestion.Replacements {
+		if i == 0 || replacement.StartLine < startLine {
+			startLine = replacement.StartLine
+		}
+		if i == 0 || replacement.EndLine > endLine {
+			endLine = replacement.EndLine
+		}
+	}
+	return
+}
+
+// Post each suggestion whose lines fall inside a single hunk of the diff as a comment on one pull request review.
+// Suggestions which cannot be placed in the diff or applied to the file are skipped. Returns the number of suggestions
+// posted.
+func (annotator *PullRequestAnnotator) PostSuggestions(suggestions []*Suggestion, reviewBody string) (int, error) {
+	if annotator.pr.translation != nil {
+		suggestions = annotator.pr.translation.translateSuggestions(suggestions)
+	}
+
+	var comments []*github.DraftReviewComment
+	for _, suggestion := range suggestions {
+		if suggestion == nil || len(suggestion.Replacements) == 0 {
+			continue
+		}
+		startLine, endLine := suggestion.lineRange()
+		if !annotator.pr.rangeInDiff(suggestion.Path, startLine, endLine) {
+			continue
+		}
+
+		lines, err := annotator.fileLines(suggestion.Path)
+		if err != nil {
+			continue
+		}
+		suggestedText, err := applyReplacements(lines, startLine, endLine, suggestion.Replacements)
+		if err != nil {
+			continue
+		}
+
+		comments = append(comments, suggestionComment(suggestion, startLine, endLine, suggestedText))
+	}
+
+	if len(comments) == 0 {
+		return 0, nil
+	}
+
+	// a rerun (e.g., of a CI job) would otherwise post the same suggestions again
+	posted, err := annotator.reviewComments()
+	if err != nil {
+		return 0, err
+	}
+	comments = withoutPostedComments(comments, posted)
+	if len(comments) == 0 {
+		return 0, nil
+	}
+
+	review := github.PullRequestReviewRequest{
+		CommitID: &annotator.pr.headSHA,
+		Body:     &reviewBody,
+		Event:    github.String("COMMENT"),
+		Comments: comments,
+	}
+	if _, _, err := annotator.client.PullRequests.CreateReview(context.Background(), annotator.pr.owner, annotator.pr.repo, annotator.pr.number, &review); err != nil {
+		return 0, errors.Wrap(explainPermissionError(err, "pull-requests: write"), "failed to create review")
+	}
+	return len(comments), nil
+}
+
+// List the review comments already on the pull request.
+func (annotator *PullRequestAnnotator) reviewComments() ([]*github.PullRequestComment, error) {
+	options := &github.PullRequestListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var comments []*github.PullRequestComment
+	for {
+		page, response, err := annotator.client.PullRequests.ListComments(context.Background(), annotator.pr.owner, annotator.pr.repo, annotator.pr.number, options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list review comments (page %d)", options.Page)
+		}
+		comments = append(comments, page...)
+
+		if response.NextPage == 0 {
+			break
+		}
+		options.Page = response.NextPage
+	}
+	return comments, nil
+}
+
+// Remove comments already posted with the same path, lines, and body.
+func withoutPostedComments(comments []*github.DraftReviewComment, posted []*github.PullRequestComment) []*github.DraftReviewComment {
+	key := func(path string, startLine int, line int, body string) string {
+		if startLine == 0 {
+			startLine = line
+		}
+		return fmt.Sprintf("%s:%d-%d:%s", path, startLine, line, body)
+	}
+	postedKeys := make(map[string]bool)
+	for _, comment := range posted {
+		postedKeys[key(comment.GetPath(), comment.GetStartLine(), comment.GetLine(), comment.GetBody())] = true
+	}
+
+	var unposted []*github.DraftReviewComment
+	for _, comment := range comments {
+		if !postedKeys[key(comment.GetPath(), comment.GetStartLine(), comment.GetLine(), comment.GetBody())] {
+			unposted = append(unposted, comment)
+		}
+	}
+	return unposted
+}
+
+func suggestionComment(suggestion *Suggestion, startLine int, endLine int, suggestedText string) *github.DraftReviewComment {
+	var body strings.Builder
+	fmt.Fprintf(&body, "**%s**", suggestion.Title)
+	if message := strings.TrimSpace(suggestion.Message); message != "" {
+		fmt.Fprintf(&body, ": %s", message)
+	}
+	if description := strings.TrimSpace(suggestion.Description); description != "" {
+		fmt.Fprintf(&body, "\n\n%s", description)
+	}
+	fmt.Fprintf(&body, "\n\n```suggestion\n%s\n```", suggestedText)
+
+	comment := &github.DraftReviewComment{
+		Path: github.String(suggestion.Path),
+		Body: github.String(body.String()),
+		Side: github.String("RIGHT"),
+		Line: github.Int(endLine),
+	}
+	if startLine != endLine {
+		comment.StartLine = github.Int(startLine)
+		comment.StartSide = github.String("RIGHT")
+	}
+	return comment
+}
+
+// Load the lines of a file at the head commit of the pull request, caching them for later suggestions.
+func (annotator *PullRequestAnnotator) fileLines(path string) ([]string, error) {
+	if lines, found := annotator.fileContents[path]; found {
+		return lines, nil
+	}
+
+	options := github.RepositoryContentGetOptions{Ref: annotator.pr.headSHA}
+	file, _, _, err := annotator.client.Repositories.GetContents(context.Background(), annotator.pr.owner, annotator.pr.repo, path, &options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get contents of %q", path)
+	}
+	if file == nil {
+		return nil, errors.Errorf("%q is not a file", path)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode contents of %q", path)
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	if annotator.fileContents == nil {
+		annotator.fileContents = make(map[string][]string)
+	}
+	annotator.fileContents[path] = lines
+	return lines, nil
+}
+
+// Apply replacements to lines startLine through endLine (one-indexed, inclusive) of a file, returning the resulting
+// text of those lines. Replacements must not overlap.
+func applyReplacements(lines []string, startLine int, endLine int, replacements []SuggestionReplacement) (string, error) {
+	if startLine < 1 || endLine < startLine || endLine > len(lines) {
+		return "", errors.Errorf("lines %d-%d are outside the file (%d lines)", startLine, endLine, len(lines))
+	}
+	block := []rune(strings.Join(lines[startLine-1:endLine], "\n"))
+
+	// offset of a (line, column) position within the block
+	offset := func(line int, column int, isEnd bool) (int, error) {
+		if line < startLine || line > endLine {
+			return 0, errors.Errorf("line %d is outside lines %d-%d", line, startLine, endLine)
+		}
+		lineLength := len([]rune(lines[line-1]))
+		if column == 0 {
+			column = 1
+			if isEnd {
+				column = lineLength + 1
+			}
+		}
+		if column < 1 || column > lineLength+1 {
+			return 0, errors.Errorf("column %d is outside line %d", column, line)
+		}
+
+		lineOffset := 0
+		for i := startLine; i < line; i++ {
+			lineOffset += len([]rune(lines[i-1])) + 1 // include the newline
+		}
+		return lineOffset + column - 1, nil
+	}
+
+	type span struct {
+		start, end int
+		text       string
+	}
+	var spans []span
+	for _, replacement := range replacements {
+		start, err := offset(replacement.StartLine, replacement.StartColumn, false)
+		if err != nil {
+			return "", err
+		}
+		end, err := offset(replacement.EndLine, replacement.EndColumn, true)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", errors.Errorf("replacement ends before it starts (line %d)", replacement.StartLine)
+		}
+		spans = append(spans, span{start: start, end: end, text: replacement.Text})
+	}
+
+	// apply from the end of the block so earlier offsets remain valid
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for i, s := range spans {
+		if i > 0 && s.end > spans[i-1].start {
+			return "", errors.New("replacements overlap")
+		}
+		block = append(block[:s.start], append([]rune(s.text), block[s.end:]...)...)
+	}
+
+	return strings.TrimSuffix(string(block), "\n"), nil
+}
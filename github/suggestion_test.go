@@ -0,0 +1,164 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v47/github"
+)
+
+func TestApplyReplacements(t *testing.T) {
+	lines := []string{
+		"import os",
+		"",
+		"def main():",
+		"    print('hi')",
+		"    print('there')",
+	}
+
+	tests := []struct {
+		name               string
+		startLine, endLine int
+		replacements       []SuggestionReplacement
+		expected           string
+		errMessage         string
+	}{
+		{
+			"whole line",
+			4, 4,
+			[]SuggestionReplacement{{StartLine: 4, EndLine: 4, Text: "    logger.info('hi')"}},
+			"    logger.info('hi')",
+			"",
+		},
+		{
+			"columns within a line",
+			4, 4,
+			[]SuggestionReplacement{{StartLine: 4, StartColumn: 5, EndLine: 4, EndColumn: 10, Text: "logger.info"}},
+			"    logger.info('hi')",
+			"",
+		},
+		{
+			"across lines",
+			4, 5,
+			[]SuggestionReplacement{{StartLine: 4, StartColumn: 11, EndLine: 5, EndColumn: 19, Text: "'hi there')"}},
+			"    print('hi there')",
+			"",
+		},
+		{
+			"multiple replacements",
+			4, 5,
+			[]SuggestionReplacement{
+				{StartLine: 4, StartColumn: 5, EndLine: 4, EndColumn: 10, Text: "log"},
+				{StartLine: 5, StartColumn: 5, EndLine: 5, EndColumn: 10, Text: "log"},
+			},
+			"    log('hi')\n    log('there')",
+			"",
+		},
+		{
+			"deletion",
+			4, 4,
+			[]SuggestionReplacement{{StartLine: 4, EndLine: 4}},
+			"",
+			"",
+		},
+		{
+			"outside the file",
+			6, 6,
+			[]SuggestionReplacement{{StartLine: 6, EndLine: 6}},
+			"",
+			"lines 6-6 are outside the file (5 lines)",
+		},
+		{
+			"column outside the line",
+			1, 1,
+			[]SuggestionReplacement{{StartLine: 1, StartColumn: 20, EndLine: 1}},
+			"",
+			"column 20 is outside line 1",
+		},
+		{
+			"overlapping",
+			4, 4,
+			[]SuggestionReplacement{
+				{StartLine: 4, StartColumn: 1, EndLine: 4, EndColumn: 8, Text: "a"},
+				{StartLine: 4, StartColumn: 5, EndLine: 4, EndColumn: 10, Text: "b"},
+			},
+			"",
+			"replacements overlap",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyReplacements(lines, tt.startLine, tt.endLine, tt.replacements)
+			if tt.errMessage != "" {
+				if err == nil || err.Error() != tt.errMessage {
+					t.Errorf("Expected error %q but got %q.", tt.errMessage, err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error but got %q.", err)
+			} else if got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRangeInDiff(t *testing.T) {
+	pr := pullRequest{
+		files: []*pullRequestFile{{filename: "src/main.go", lineBounds: []lineBound{{1, 5}, {11, 15}}}},
+	}
+
+	tests := []struct {
+		name               string
+		filename           string
+		startLine, endLine int
+		inDiff             bool
+	}{
+		{"inside a hunk", "src/main.go", 2, 4, true},
+		{"whole hunk", "src/main.go", 11, 15, true},
+		{"spanning hunks", "src/main.go", 4, 12, false},
+		{"outside hunks", "src/main.go", 7, 7, false},
+		{"other file", "src/other.go", 2, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pr.rangeInDiff(tt.filename, tt.startLine, tt.endLine); got != tt.inDiff {
+				t.Errorf("expected %t but got %t", tt.inDiff, got)
+			}
+		})
+	}
+}
+
+func TestSuggestionComment(t *testing.T) {
+	suggestion := Suggestion{Path: "src/main.py", Title: "no-print", Message: "use a logger", Description: "replace print"}
+
+	single := suggestionComment(&suggestion, 4, 4, "    logger.info('hi')")
+	if single.GetLine() != 4 || single.StartLine != nil || single.GetSide() != "RIGHT" {
+		t.Errorf("unexpected single line comment %s", single)
+	}
+	expectedBody := "**no-print**: use a logger\n\nreplace print\n\n```suggestion\n    logger.info('hi')\n```"
+	if single.GetBody() != expectedBody {
+		t.Errorf("expected body %q but got %q", expectedBody, single.GetBody())
+	}
+
+	multi := suggestionComment(&suggestion, 4, 5, "a\nb")
+	if multi.GetStartLine() != 4 || multi.GetLine() != 5 || multi.GetStartSide() != "RIGHT" {
+		t.Errorf("unexpected multi line comment %s", multi)
+	}
+}
+
+func TestWithoutPostedComments(t *testing.T) {
+	suggestion := Suggestion{Path: "src/main.py", Title: "no-print"}
+	single := suggestionComment(&suggestion, 4, 4, "logger.info('hi')")
+	multi := suggestionComment(&suggestion, 4, 5, "a\nb")
+	other := suggestionComment(&suggestion, 9, 9, "logger.info('bye')")
+
+	posted := []*github.PullRequestComment{
+		{Path: github.String("src/main.py"), Line: github.Int(4), Body: single.Body},
+		{Path: github.String("src/main.py"), StartLine: github.Int(4), Line: github.Int(5), Body: multi.Body},
+		// the same body on another line is a different suggestion
+		{Path: github.String("src/main.py"), Line: github.Int(10), Body: other.Body},
+	}
+	got := withoutPostedComments([]*github.DraftReviewComment{single, multi, other}, posted)
+	if len(got) != 1 || got[0] != other {
+		t.Errorf("expected only the unposted comment but got %v", got)
+	}
+}
@@ -0,0 +1,308 @@
+package github
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// GitHub rejects check run summaries and texts longer than 65535 characters.
+const maxOutputLength = 65535
+
+// The number of files listed in the most affected files table.
+const maxTopFiles = 10
+
+// A finding which could not be attached to a line of code (e.g., it has no location), reported in the check summary
+// instead of as an annotation.
+type UnannotatedFinding struct {
+	Title, Message, Level string
+	Path                  string
+	Reason                string
+}
+
+type ToolDescription struct {
+	Name, Version string
+}
+
+type RuleDescription struct {
+	ID, Name    string
+	Description string
+	HelpURI     string
+}
+
+// Details about a check run which are reported in its summary rather than as annotations.
+type CheckRunDetails struct {
+	Tools               []ToolDescription
+	Rules               []RuleDescription
+	UnannotatedFindings []*UnannotatedFinding
+	// findings on the pull request's base commit which are no longer reported
+	FixedFindings []*UnannotatedFinding
+	// the pull request's latest head, when it has moved past the scanned commit
+	MovedHeadSHA string
+	// whether the comparison of the scanned commit listed too many files, so the pull request's files were used instead
+	ComparisonTruncated bool
+	// the scanned commit, when its findings were moved onto a later commit
+	TranslatedFromSHA string
+	// the pull request's latest head, when findings could not be moved onto it, and why
+	UntranslatableHeadSHA, UntranslatableReason string
+	// why the check failed under its conclusion policy, when it did
+	ConclusionReason string
+	// problems with the repository's configuration file, which was ignored because of them
+	ConfigurationErrors []string
+}
+
+// Render the markdown summary (an overview) and text (the detailed report) of a check run. annotations are those
+// being posted, while filteredAnnotations were dropped because they fall outside the pull request's diff.
+func renderOutput(checkName string, headSHA string, annotations []*Annotation, filteredAnnotations []*Annotation, details CheckRunDetails) (summary string, text string) {
+	summarySections := []string{
+		fmt.Sprintf("A set of findings for %s on commit %s.", checkName, headSHA),
+		levelCountsTable(annotations),
+	}
+	if hasNoFindings(annotations, details) {
+		summarySections = []string{fmt.Sprintf("No findings for %s on commit %s.", checkName, headSHA)}
+	}
+
+	var notes []string
+	if details.ConclusionReason != "" {
+		notes = append(notes, details.ConclusionReason)
+	}
+	if len(details.ConfigurationErrors) > 0 {
+		notes = append(notes, fmt.Sprintf("The repository's configuration file was ignored because it is invalid: %s.", strings.Join(details.ConfigurationErrors, "; ")))
+	}
+	if details.MovedHeadSHA != "" {
+		notes = append(notes, fmt.Sprintf("The pull request's head has moved to %s since this commit was scanned, so some annotations may not match its latest changes.", details.MovedHeadSHA))
+	}
+	if details.ComparisonTruncated {
+		notes = append(notes, fmt.Sprintf("GitHub's compare API lists at most %d files, so annotations were filtered by the pull request's latest files rather than this commit's (a local git checkout can diff this commit instead).", maxComparedFiles))
+	}
+	if details.TranslatedFromSHA != "" {
+		notes = append(notes, fmt.Sprintf("Findings from the scan of commit %s were moved onto the matching lines of this commit.", details.TranslatedFromSHA))
+	}
+	if details.UntranslatableHeadSHA != "" {
+		notes = append(notes, fmt.Sprintf("Findings were not moved onto the pull request's latest head %s, as %s.", details.UntranslatableHeadSHA, details.UntranslatableReason))
+	}
+	if unchanged := countUnchanged(annotations); unchanged > 0 {
+		notes = append(notes, fmt.Sprintf("%d of these findings also exist on the base commit and do not affect the conclusion.", unchanged))
+	}
+	if len(filteredAnnotations) > 0 {
+		notes = append(notes, fmt.Sprintf("%d findings outside of the pull request's diff were not annotated.", len(filteredAnnotations)))
+	}
+	if len(details.UnannotatedFindings) > 0 {
+		notes = append(notes, fmt.Sprintf("%d findings could not be annotated.", len(details.UnannotatedFindings)))
+	}
+	if len(details.FixedFindings) > 0 {
+		notes = append(notes, fmt.Sprintf("%d findings on the base commit have been fixed.", len(details.FixedFindings)))
+	}
+	if len(notes) > 0 {
+		summarySections = append(summarySections, strings.Join(notes, "\n"))
+	}
+	if tools := toolsAsMarkdown(details.Tools); tools != "" {
+		summarySections = append(summarySections, tools)
+	}
+
+	var textSections []string
+	if len(annotations) > 0 {
+		textSections = append(textSections, rulesSection(annotations, details.Rules), topFilesSection(annotations))
+	}
+	if len(details.UnannotatedFindings) > 0 {
+		textSections = append(textSections, findingsSection("Findings without annotations", details.UnannotatedFindings))
+	}
+	if len(details.FixedFindings) > 0 {
+		textSections = append(textSections, findingsSection("Fixed findings", details.FixedFindings))
+	}
+	if len(filteredAnnotations) > 0 {
+		textSections = append(textSections, findingsSection("Findings outside the diff", annotationsToFindings(filteredAnnotations)))
+	}
+
+	summary = truncateMarkdown(strings.Join(summarySections, "\n\n"), maxOutputLength)
+	text = truncateMarkdown(strings.Join(textSections, "\n\n"), maxOutputLength)
+	return summary, text
+}
+
+// Whether a check run has nothing to report, either as annotations or in its summary. Findings outside the diff and
+// fixed findings are not counted, as they are not findings on the pull request's changes.
+func hasNoFindings(annotations []*Annotation, details CheckRunDetails) bool {
+	return len(annotations) == 0 && len(details.UnannotatedFindings) == 0
+}
+
+func levelCountsTable(annotations []*Annotation) string {
+	counts := make(map[int]int)
+	for _, annotation := range annotations {
+		counts[annotation.level] += 1
+	}
+
+	rows := []string{"| Level | Findings |", "| --- | ---: |"}
+	for _, level := range []int{failureLevel, warningLevel, noticeLevel} {
+		rows = append(rows, fmt.Sprintf("| %s | %d |", levelName(level), counts[level]))
+	}
+	return strings.Join(rows, "\n")
+}
+
+func levelName(level int) string {
+	switch level {
+	case failureLevel:
+		return "Failure"
+	case warningLevel:
+		return "Warning"
+	case noticeLevel:
+		return "Notice"
+	}
+	return "Unknown"
+}
+
+func countUnchanged(annotations []*Annotation) int {
+	count := 0
+	for _, annotation := range annotations {
+		if annotation.unchanged {
+			count += 1
+		}
+	}
+	return count
+}
+
+func toolsAsMarkdown(tools []ToolDescription) string {
+	var descriptions []string
+	for _, tool := range tools {
+		if tool.Version != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s %s", tool.Name, tool.Version))
+		} else {
+			descriptions = append(descriptions, tool.Name)
+		}
+	}
+	if len(descriptions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("_Reported by %s._", strings.Join(descriptions, ", "))
+}
+
+// A table of the rules with annotations, most frequent first, linking each to its documentation.
+func rulesSection(annotations []*Annotation, rules []RuleDescription) string {
+	ruleIDToRule := make(map[string]RuleDescription)
+	for _, rule := range rules {
+		ruleIDToRule[rule.ID] = rule
+	}
+
+	ruleIDToCount := make(map[string]int)
+	for _, annotation := range annotations {
+		ruleIDToCount[annotation.ruleID] += 1
+	}
+
+	rows := []string{"### Rules\n", "| Rule | Description | Findings |", "| --- | --- | ---: |"}
+	for _, ruleID := range keysByCount(ruleIDToCount) {
+		rule := ruleIDToRule[ruleID]
+
+		name := fmt.Sprintf("`%s`", ruleID)
+		if rule.HelpURI != "" {
+			name = fmt.Sprintf("[%s](%s)", name, rule.HelpURI)
+		}
+		if rule.Name != "" && rule.Name != ruleID {
+			name = fmt.Sprintf("%s %s", name, rule.Name)
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %s | %d |", escapeTableCell(name), escapeTableCell(rule.Description), ruleIDToCount[ruleID]))
+	}
+	return strings.Join(rows, "\n")
+}
+
+func topFilesSection(annotations []*Annotation) string {
+	fileToCount := make(map[string]int)
+	for _, annotation := range annotations {
+		fileToCount[annotation.fileName] += 1
+	}
+
+	files := keysByCount(fileToCount)
+	rows := []string{"### Most affected files\n", "| File | Findings |", "| --- | ---: |"}
+	for i, file := range files {
+		if i == maxTopFiles {
+			rows = append(rows, fmt.Sprintf("| _%d more files_ | |", len(files)-maxTopFiles))
+			break
+		}
+		rows = append(rows, fmt.Sprintf("| `%s` | %d |", escapeTableCell(file), fileToCount[file]))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// Sort the keys of a map by descending count, breaking ties alphabetically.
+func keysByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func escapeTableCell(cell string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(cell, "|", "\\|"), "\n", " ")
+}
+
+func annotationsToFindings(annotations []*Annotation) []*UnannotatedFinding {
+	var findings []*UnannotatedFinding
+	for _, annotation := range annotations {
+		finding := &UnannotatedFinding{
+			Title: annotation.ruleID,
+			Path:  fmt.Sprintf("%s:%d", annotation.fileName, annotation.startLine),
+			Level: strings.ToLower(levelName(annotation.level)),
+		}
+		if annotation.githubAnnotation != nil && annotation.githubAnnotation.Message != nil {
+			finding.Message = *annotation.githubAnnotation.Message
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+func findingsSection(heading string, findings []*UnannotatedFinding) string {
+	lines := []string{fmt.Sprintf("### %s (%d)\n", heading, len(findings))}
+	for _, finding := range findings {
+		lines = append(lines, findingAsMarkdown(finding))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func findingAsMarkdown(finding *UnannotatedFinding) string {
+	line := fmt.Sprintf("- **%s**", finding.Title)
+	if finding.Level != "" {
+		line = fmt.Sprintf("%s (%s)", line, finding.Level)
+	}
+	if finding.Path != "" {
+		line = fmt.Sprintf("%s in `%s`", line, finding.Path)
+	}
+	if message := strings.TrimSpace(finding.Message); message != "" {
+		line = fmt.Sprintf("%s: %s", line, strings.ReplaceAll(message, "\n", " "))
+	}
+	if finding.Reason != "" {
+		line = fmt.Sprintf("%s _(%s)_", line, finding.Reason)
+	}
+	return line
+}
+
+// Truncate markdown to at most limit bytes, cutting at a line boundary and noting the truncation.
+func truncateMarkdown(markdown string, limit int) string {
+	if len(markdown) <= limit {
+		return markdown
+	}
+
+	const truncationNotice = "\n\n_This report was truncated because it exceeds GitHub's size limit._"
+	truncated := truncateUTF8(markdown, limit-len(truncationNotice))
+	if lastNewline := strings.LastIndex(truncated, "\n"); lastNewline > 0 {
+		truncated = truncated[:lastNewline]
+	}
+	return truncated + truncationNotice
+}
+
+// Cut a string to at most limit bytes, without splitting a multi-byte character (which GitHub rejects as invalid UTF-8).
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
@@ -0,0 +1,189 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderOutput(t *testing.T) {
+	failure, _ := CreateAnnotation("src/main.py", 4, 4, "error", "sqli", "tainted query")
+	warning, _ := CreateAnnotation("src/main.py", 9, 9, "warning", "no-print", "no print")
+	otherWarning, _ := CreateAnnotation("src/other.py", 2, 2, "warning", "no-print", "no print")
+	unchangedWarning, _ := CreateAnnotation("src/old.py", 2, 2, "warning", "no-print", "no print")
+	unchangedWarning.MarkUnchanged()
+	filtered, _ := CreateAnnotation("src/untouched.py", 12, 12, "note", "todo", "resolve\nthis")
+
+	tests := []struct {
+		name                             string
+		annotations, filteredAnnotations []*Annotation
+		details                          CheckRunDetails
+		summary, text                    string
+	}{
+		{
+			"no findings",
+			nil,
+			nil,
+			CheckRunDetails{},
+			"No findings for semgrep on commit abc123.",
+			"",
+		},
+		{
+			"every finding outside the diff",
+			nil,
+			[]*Annotation{filtered},
+			CheckRunDetails{Tools: []ToolDescription{{Name: "semgrep"}}},
+			"No findings for semgrep on commit abc123.\n\n" +
+				"1 findings outside of the pull request's diff were not annotated.\n\n" +
+				"_Reported by semgrep._",
+			"### Findings outside the diff (1)\n\n" +
+				"- **todo** (notice) in `src/untouched.py:12`: resolve this",
+		},
+		{
+			"invalid configuration",
+			nil,
+			nil,
+			CheckRunDetails{ConfigurationErrors: []string{"invalid failure level \"sometimes\"", "max_errors must not be negative"}},
+			"No findings for semgrep on commit abc123.\n\n" +
+				"The repository's configuration file was ignored because it is invalid: invalid failure level \"sometimes\"; max_errors must not be negative.",
+			"",
+		},
+		{
+			"truncated comparison",
+			nil,
+			nil,
+			CheckRunDetails{ComparisonTruncated: true},
+			"No findings for semgrep on commit abc123.\n\n" +
+				"GitHub's compare API lists at most 300 files, so annotations were filtered by the pull request's latest files rather than this commit's (a local git checkout can diff this commit instead).",
+			"",
+		},
+		{
+			"force-pushed head",
+			nil,
+			nil,
+			CheckRunDetails{MovedHeadSHA: "def456", UntranslatableHeadSHA: "def456", UntranslatableReason: "it does not contain this commit (e.g., the pull request was force-pushed)"},
+			"No findings for semgrep on commit abc123.\n\n" +
+				"The pull request's head has moved to def456 since this commit was scanned, so some annotations may not match its latest changes.\n" +
+				"Findings were not moved onto the pull request's latest head def456, as it does not contain this commit (e.g., the pull request was force-pushed).",
+			"",
+		},
+		{
+			"only findings without annotations",
+			nil,
+			nil,
+			CheckRunDetails{UnannotatedFindings: []*UnannotatedFinding{{Title: "license", Reason: "result has no location"}}},
+			"A set of findings for semgrep on commit abc123.\n\n" +
+				"| Level | Findings |\n| --- | ---: |\n| Failure | 0 |\n| Warning | 0 |\n| Notice | 0 |\n\n" +
+				"1 findings could not be annotated.",
+			"### Findings without annotations (1)\n\n" +
+				"- **license** _(result has no location)_",
+		},
+		{
+			"findings with rules and tools",
+			[]*Annotation{warning, failure, otherWarning, unchangedWarning},
+			[]*Annotation{filtered},
+			CheckRunDetails{
+				Tools: []ToolDescription{{Name: "semgrep", Version: "1.2.3"}, {Name: "CodeQL"}},
+				Rules: []RuleDescription{
+					{ID: "no-print", Name: "NoPrint", Description: "Avoid print | use logging", HelpURI: "https://example.com/no-print"},
+					{ID: "unused", Description: "Not reported"},
+				},
+				UnannotatedFindings: []*UnannotatedFinding{{Title: "license", Reason: "result has no location"}},
+				FixedFindings:       []*UnannotatedFinding{{Title: "no-eval", Level: "error", Path: "src/main.py"}},
+			},
+			"A set of findings for semgrep on commit abc123.\n\n" +
+				"| Level | Findings |\n| --- | ---: |\n| Failure | 1 |\n| Warning | 3 |\n| Notice | 0 |\n\n" +
+				"1 of these findings also exist on the base commit and do not affect the conclusion.\n" +
+				"1 findings outside of the pull request's diff were not annotated.\n" +
+				"1 findings could not be annotated.\n" +
+				"1 findings on the base commit have been fixed.\n\n" +
+				"_Reported by semgrep 1.2.3, CodeQL._",
+			"### Rules\n\n" +
+				"| Rule | Description | Findings |\n| --- | --- | ---: |\n" +
+				"| [`no-print`](https://example.com/no-print) NoPrint | Avoid print \\| use logging | 3 |\n" +
+				"| `sqli` |  | 1 |\n\n" +
+				"### Most affected files\n\n" +
+				"| File | Findings |\n| --- | ---: |\n" +
+				"| `src/main.py` | 2 |\n| `src/old.py` | 1 |\n| `src/other.py` | 1 |\n\n" +
+				"### Findings without annotations (1)\n\n" +
+				"- **license** _(result has no location)_\n\n" +
+				"### Fixed findings (1)\n\n" +
+				"- **no-eval** (error) in `src/main.py`\n\n" +
+				"### Findings outside the diff (1)\n\n" +
+				"- **todo** (notice) in `src/untouched.py:12`: resolve this",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, text := renderOutput("semgrep", "abc123", tt.annotations, tt.filteredAnnotations, tt.details)
+			if summary != tt.summary {
+				t.Errorf("expected summary %q but got %q", tt.summary, summary)
+			}
+			if text != tt.text {
+				t.Errorf("expected text %q but got %q", tt.text, text)
+			}
+		})
+	}
+}
+
+func TestTopFilesSection(t *testing.T) {
+	var annotations []*Annotation
+	for i := 0; i < maxTopFiles+3; i++ {
+		annotation, _ := CreateAnnotation(fmt.Sprintf("src/file_%02d.py", i), 1, 1, "note", "todo", "todo")
+		annotations = append(annotations, annotation)
+	}
+
+	got := topFilesSection(annotations)
+	if !strings.HasSuffix(got, "| _3 more files_ | |") {
+		t.Errorf("expected the table to note 3 more files but got %q", got)
+	}
+	if rows := strings.Count(got, "\n| `src/"); rows != maxTopFiles {
+		t.Errorf("expected %d file rows but got %d", maxTopFiles, rows)
+	}
+}
+
+func TestTruncateMarkdown(t *testing.T) {
+	short := "### Heading\n\n- one\n- two"
+	if got := truncateMarkdown(short, 100); got != short {
+		t.Errorf("expected %q to be unchanged but got %q", short, got)
+	}
+
+	long := strings.Repeat("- a finding\n", 100)
+	got := truncateMarkdown(long, 200)
+	if len(got) > 200 {
+		t.Errorf("expected at most 200 characters but got %d", len(got))
+	}
+	if !strings.HasSuffix(got, "_This report was truncated because it exceeds GitHub's size limit._") {
+		t.Errorf("expected a truncation notice but got %q", got)
+	}
+	if !strings.HasSuffix(strings.Split(got, "\n\n_This")[0], "- a finding") {
+		t.Errorf("expected truncation at a line boundary but got %q", got)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		limit    int
+		expected string
+	}{
+		{"short", "héllo", 10, "héllo"},
+		{"ascii", "hello", 3, "hel"},
+		{"at a rune boundary", "héllo", 3, "hé"},
+		{"inside a rune", "héllo", 2, "h"},
+		{"inside a four byte rune", "a😀b", 4, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateUTF8(tt.s, tt.limit)
+			if got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("expected valid UTF-8 but got %q", got)
+			}
+		})
+	}
+}
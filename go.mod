@@ -8,6 +8,7 @@ require (
 	github.com/google/go-github/v47 v47.1.0
 	github.com/owenrumney/go-sarif v1.1.1
 	github.com/pkg/errors v0.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"less-advanced-security/sarif"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// A flag which may be repeated (--flag=a --flag=b) or given a comma-separated list (--flag=a,b).
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
+
+var sarifFileExtensions = []string{".sarif", ".json"}
+
+// Expand a list of sarif inputs into a deduplicated list of files. Each input may be a file, a directory (whose sarif
+// files are included, non-recursively), or a glob pattern.
+func expandSarifPaths(inputs []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, input := range inputs {
+		if strings.ContainsAny(input, "*?[") {
+			matches, err := filepath.Glob(input)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid glob %q", input)
+			}
+			sort.Strings(matches)
+			found := false
+			for _, match := range matches {
+				if info, err := os.Stat(match); err != nil || info.IsDir() {
+					continue
+				}
+				found = true
+				add(match)
+			}
+			if !found {
+				return nil, errors.Errorf("no files match %q", input)
+			}
+			continue
+		}
+
+		info, err := os.Stat(input)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, errors.Errorf("no file exists at %q", input)
+			}
+			return nil, errors.Wrapf(err, "failed to read %q", input)
+		}
+		if !info.IsDir() {
+			add(input)
+			continue
+		}
+
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read directory %q", input)
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.IsDir() || !hasSarifExtension(entry.Name()) {
+				continue
+			}
+			found = true
+			add(filepath.Join(input, entry.Name()))
+		}
+		if !found {
+			return nil, errors.Errorf("no sarif files found in directory %q", input)
+		}
+	}
+
+	return paths, nil
+}
+
+func hasSarifExtension(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+	for _, sarifExtension := range sarifFileExtensions {
+		if extension == sarifExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse each sarif file concurrently, returning all runs in the order of the given paths.
+func parseSarifFiles(paths []string) ([]*sarif.Run, error) {
+	runsPerPath := make([][]*sarif.Run, len(paths))
+	errs := make([]error, len(paths))
+
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			runsPerPath[i], errs[i] = sarif.ParseFromFile(path)
+		}(i, path)
+	}
+	wg.Wait()
+
+	var runs []*sarif.Run
+	for i, path := range paths {
+		if errs[i] != nil {
+			return nil, errors.Wrapf(errs[i], "failed to parse %q", path)
+		}
+		runs = append(runs, runsPerPath[i]...)
+	}
+	return runs, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStringListFlag(t *testing.T) {
+	var f stringListFlag
+	for _, value := range []string{"a.sarif", "b.sarif, c.sarif", ",,d.sarif"} {
+		if err := f.Set(value); err != nil {
+			t.Fatalf("expected no error but received %q", err)
+		}
+	}
+
+	expected := stringListFlag{"a.sarif", "b.sarif", "c.sarif", "d.sarif"}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("expected %v but got %v", expected, f)
+	}
+}
+
+func TestExpandSarifPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"semgrep.sarif", "brakeman.json", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{}, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested.sarif"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	emptyDir := t.TempDir()
+
+	semgrep := filepath.Join(dir, "semgrep.sarif")
+	brakeman := filepath.Join(dir, "brakeman.json")
+	notes := filepath.Join(dir, "notes.txt")
+
+	tests := []struct {
+		name       string
+		inputs     []string
+		paths      []string
+		errMessage string
+	}{
+		{"no inputs", []string{}, nil, ""},
+		{"one file", []string{semgrep}, []string{semgrep}, ""},
+		{"any extension when named directly", []string{notes}, []string{notes}, ""},
+		{"directory", []string{dir}, []string{brakeman, semgrep}, ""},
+		{"glob", []string{filepath.Join(dir, "*.sarif")}, []string{semgrep}, ""},
+		{"duplicates", []string{semgrep, dir, semgrep}, []string{semgrep, brakeman}, ""},
+		{"missing file", []string{filepath.Join(dir, "missing.sarif")}, nil, "no file exists at \"" + filepath.Join(dir, "missing.sarif") + "\""},
+		{"glob without matches", []string{filepath.Join(dir, "*.xml")}, nil, "no files match \"" + filepath.Join(dir, "*.xml") + "\""},
+		{"directory without sarif files", []string{emptyDir}, nil, "no sarif files found in directory \"" + emptyDir + "\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandSarifPaths(tt.inputs)
+			if tt.errMessage != "" {
+				if err == nil || err.Error() != tt.errMessage {
+					t.Errorf("Expected error %q but got %q.", tt.errMessage, err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error but got %q.", err)
+			} else if !reflect.DeepEqual(got, tt.paths) {
+				t.Errorf("expected paths %v but got %v", tt.paths, got)
+			}
+		})
+	}
+}
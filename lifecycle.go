@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"less-advanced-security/github"
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Create an in_progress check run before a scan, recording it for finish (or fail) to complete.
+func startCheckRun(checker *github.CommitChecker, sha string, checkName string, externalID string, statePath string) error {
+	if checkName == "" {
+		return errors.New("start requires --check_name, as the tool is not known until the scan completes")
+	}
+
+	state, err := loadRunState(statePath)
+	if err != nil {
+		return err
+	}
+	forgetOtherCommits(state, sha)
+
+	id, err := checker.StartCheckRun(checkName, externalID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to start check run for %s", checkName)
+	}
+	state.CheckRuns[checkName] = id
+	if err := state.save(statePath); err != nil {
+		return errors.Wrapf(err, "started check run %d but failed to record it", id)
+	}
+
+	log.Printf("Started check run %d for %s.\n", id, checkName)
+	return nil
+}
+
+// Mark the check runs recorded by start as failed, reporting the scanner's error output. Without a started check run,
+// a failed check run named checkName is created instead.
+func failCheckRuns(checker *github.CommitChecker, sha string, checkName string, externalID string, statePath string, errorPath string) error {
+	errorOutput, err := readErrorOutput(errorPath)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadRunState(statePath)
+	if err != nil {
+		return err
+	}
+	forgetOtherCommits(state, sha)
+
+	checkRuns := state.CheckRuns
+	if len(checkRuns) == 0 {
+		if checkName == "" {
+			return errors.New("fail requires --check_name when no check run was started")
+		}
+		checkRuns = map[string]int64{checkName: 0}
+	}
+
+	for name, id := range checkRuns {
+		if err := checker.FailCheckRun(id, name, externalID, errorOutput); err != nil {
+			return errors.Wrapf(err, "failed to mark check run for %s as failed", name)
+		}
+		log.Printf("Marked check run for %s as failed.\n", name)
+	}
+
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove state at %q", statePath)
+	}
+	return nil
+}
+
+// Forget check runs started on a commit other than sha, warning that they are left in progress.
+func forgetOtherCommits(state *runState, sha string) {
+	if forgotten := state.forCommit(sha); forgotten > 0 {
+		log.Printf("Warning: ignoring %d check runs started on another commit, which stay in progress.\n", forgotten)
+	}
+}
+
+func readErrorOutput(errorPath string) (string, error) {
+	switch errorPath {
+	case "":
+		return "", nil
+	case "-":
+		contents, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read error output from stdin")
+		}
+		return string(contents), nil
+	}
+
+	contents, err := os.ReadFile(errorPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read error output from %q", errorPath)
+	}
+	return string(contents), nil
+}
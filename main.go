@@ -6,7 +6,9 @@ import (
 	"less-advanced-security/github"
 	"less-advanced-security/sarif"
 	"log"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -23,16 +25,69 @@ func main() {
 	prNumber := flag.Int("pr", -1, "id of pr to annotate")
 
 	appID := flag.Int("app_id", -1, "app id for your GitHub app")
-	installID := flag.Int("install_id", -1, "install id for your GitHub app installation")
-	appKeyPath := flag.String("key_path", "", "absolute path to your GitHub app's private key")
+	installID := flag.Int("install_id", -1, "install id for your GitHub app installation, defaults to the installation on --repo")
+	appKeyPath := flag.String("key_path", "", "absolute path to your GitHub app's private key, or - to read it from stdin")
+	appKeyEnv := flag.String("key_env", "", "environment variable holding your GitHub app's private key, as PEM or base64-encoded PEM (instead of --key_path)")
+	tokenEnv := flag.String("token_env", "", "environment variable holding a token to authenticate with instead of a GitHub app (e.g., GITHUB_TOKEN or a personal access token)")
+	apiURL := flag.String("api_url", os.Getenv("GITHUB_API_URL"), "base URL of the GitHub Enterprise Server API (e.g., https://github.example.com/api/v3/), defaults to $GITHUB_API_URL or github.com")
+	uploadURL := flag.String("upload_url", os.Getenv("GITHUB_UPLOAD_URL"), "base URL of the GitHub Enterprise Server upload API, defaults to $GITHUB_UPLOAD_URL or /api/uploads/ on the host of --api_url")
+	caBundle := flag.String("ca_bundle", "", "path to PEM certificates to trust in addition to the system's (e.g., an internal certificate authority)")
+	proxyURL := flag.String("proxy_url", "", "proxy for requests to GitHub, overriding $HTTPS_PROXY, $HTTP_PROXY, and $NO_PROXY")
+	apiTimeout := flag.Duration("api_timeout", 10*time.Minute, "how long to keep retrying GitHub API requests which fail transiently (e.g., server errors and rate limits), default 10m")
 
-	sarifPath := flag.String("sarif_path", "", "absolute path to your sarif file")
+	var sarifPaths stringListFlag
+	flag.Var(&sarifPaths, "sarif_path", "path to a sarif file, a directory of sarif files, or a glob (may be repeated or comma-separated)")
 	checkNameOverride := flag.String("check_name", "", "name of the check, defaults to tool name from sarif")
+	var baselineSarifPaths stringListFlag
+	flag.Var(&baselineSarifPaths, "baseline_sarif_path", "path(s) to sarif from a scan of the pull request's base commit; findings also found there do not affect the check's conclusion")
+	sourceRoot := flag.String("source_root", "", "absolute path the repository was checked out to for the scan, which absolute paths in the sarif are made relative to, defaults to $GITHUB_WORKSPACE or the current directory")
+	var stripPathPrefixes stringListFlag
+	flag.Var(&stripPathPrefixes, "strip_path_prefix", "prefix to remove from paths in the sarif (may be repeated or comma-separated)")
+	var ignorePaths stringListFlag
+	flag.Var(&ignorePaths, "ignore_path", "path, directory (ending in /), or glob whose findings are never reported (may be repeated or comma-separated)")
+	addPathPrefix := flag.String("add_path_prefix", "", "prefix to add to paths in the sarif (e.g., the subdirectory of the repository a scan ran in)")
+	securitySeverityError := flag.Float64("security_severity_error", 7.0, "security-severity score (e.g., CVSS) at or above which findings are errors, default 7.0")
+	securitySeverityWarning := flag.Float64("security_severity_warning", 4.0, "security-severity score at or above which findings are warnings (lower scores are notes), default 4.0")
+	var ruleLevels, toolLevels stringListFlag
+	flag.Var(&ruleLevels, "rule_level", "override the level of a rule's findings, as ruleId=level (may be repeated or comma-separated)")
+	flag.Var(&toolLevels, "tool_level", "override the level of a tool's findings, as toolName=level (may be repeated or comma-separated)")
+	defaultLevel := flag.String("default_level", "warning", "level of findings whose level is missing or unknown: error, warning, note, or none, default warning")
+	failOnFlag := flag.String("fail_on", string(github.FailOnError), "the lowest level of new findings which fails the check: error, warning, note, or never, default error")
+	maxErrors := flag.Int("max_errors", -1, "the number of new error findings tolerated before failing the check, overriding --fail_on, default unset")
+	maxWarnings := flag.Int("max_warnings", -1, "the number of new warning findings tolerated before failing the check, overriding --fail_on, default unset")
+	maxNotes := flag.Int("max_notes", -1, "the number of new note findings tolerated before failing the check, overriding --fail_on, default unset")
+	noFindingsConclusionFlag := flag.String("no_findings_conclusion", string(github.NoFindingsSuccess), "the conclusion of checks without findings: success, neutral, or skip (post no check when there are no results), default success")
+	exitOnFailure := flag.Bool("exit_on_failure", false, "exit with status 2 when any check concludes with a failure, default false")
+	mergeRuns := flag.Bool("merge_runs", false, "post all runs from all sarif files as a single check (rather than one check per tool), default false")
 
+	existingCheckRunsFlag := flag.String("existing_checks", string(github.ReuseCheckRunPolicy), "what to do with check runs of the same name already on the commit: reuse (update in place when possible, otherwise supersede), supersede (mark as superseded by a new run), or create (leave untouched), default reuse")
+	externalID := flag.String("external_id", "", "a reference to set on the check run (e.g., a CI job id), which existing check runs must also match to be reused or superseded")
+
+	diffSourceFlag := flag.String("diff_source", string(github.CompareDiffSource), "where to read the pull request's diff from when filtering annotations: compare (the base commit against --sha), files (the pull request's latest files), or git (a local checkout containing the base commit and --sha), default compare")
+	gitDirectory := flag.String("git_dir", ".", "path to the local checkout used by --diff_source=git")
+	translateToHead := flag.Bool("translate_to_head", false, "when the pull request's head has moved past --sha, move findings onto the matching lines of the head and annotate it instead, default false")
 	filterAnnotations := flag.Bool("filter_annotations", true, "filter annotations by lines found in the git patches, default true")
+	filterGranularityFlag := flag.String("filter_granularity", string(github.HunkFilterGranularity), "which lines of the diff findings are annotated on when filtering: file (any line of a changed file), hunk (changed lines and their surrounding context), or added (added lines only), default hunk")
+	addedLineContext := flag.Int("added_line_context", 0, "with --filter_granularity=added, also annotate findings this many lines either side of an added line, default 0")
+	unpatchedFilesFlag := flag.String("unpatched_files", string(github.SkipUnpatchedFilePolicy), "what to do with findings in added or renamed files which GitHub returns no patch for: skip (never annotate them) or whole_file (treat the whole file as changed), default skip")
+	locationPolicyFlag := flag.String("location_policy", string(primaryLocationPolicy), "which locations of a finding to annotate: primary, all, or summary (findings with multiple locations are reported in the check summary), default primary")
+	annotateRelatedLocations := flag.Bool("annotate_related_locations", false, "post notice annotations on related locations and code flow steps which fall inside the diff, default false")
+	postSuggestions := flag.Bool("post_suggestions", false, "post fixes from the sarif as suggested changes in a pull request review, default false")
 	annotateStartLineOnly := flag.Bool("annotate_beginning", true, "force annotations to start line of a finding (if set to false, GitHub default of end is used), default true")
 
-	flag.Parse()
+	configPath := flag.String("config_path", ".less-advanced-security.yml", "path of the configuration file in the repository, whose values apply to flags which are not set (empty to disable)")
+	configSourceFlag := flag.String("config_source", string(githubConfigSource), "where to read the configuration file from: github (the scanned commit, via the API) or local (the checkout at --source_root), default github")
+
+	statePath := flag.String("state_path", ".less-advanced-security-state.json", "file in which start records its check run for finish and fail to complete, and posting records its progress so that a failed run resumes when run again")
+	errorPath := flag.String("error_path", "", "file containing the scanner's error output to report with fail (use - for stdin)")
+
+	// An optional command (start, finish, or fail) precedes the flags; without one, a completed check is posted at once.
+	command := ""
+	arguments := os.Args[1:]
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		command, arguments = arguments[0], arguments[1:]
+	}
+	flag.CommandLine.Parse(arguments)
 
 	if *versionFlag {
 		fmt.Printf("%s\n", version)
@@ -40,37 +95,322 @@ func main() {
 	}
 
 	parsedRepo := strings.Split(*repo, "/")
+	if *appKeyPath == "-" && *errorPath == "-" {
+		log.Fatal("--key_path and --error_path cannot both read stdin")
+	}
+	clientConfiguration, err := credentials{appID: *appID, installID: *installID, keyPath: *appKeyPath, keyEnv: *appKeyEnv, tokenEnv: *tokenEnv}.clientConfiguration(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	clientConfiguration.APIURL = *apiURL
+	clientConfiguration.UploadURL = *uploadURL
+	clientConfiguration.CABundlePath = *caBundle
+	clientConfiguration.ProxyURL = *proxyURL
+	clientConfiguration.RetryDeadline = time.Now().Add(*apiTimeout)
+	clientConfiguration.Stats = &github.APIStats{}
+	defer func() {
+		log.Println(clientConfiguration.Stats)
+	}()
+	// log.Fatal skips deferred calls, so failures report the API usage themselves
+	fatal := func(v ...interface{}) {
+		log.Println(clientConfiguration.Stats)
+		log.Fatal(v...)
+	}
+	if clientConfiguration.Token == "" && clientConfiguration.InstallationID <= 0 {
+		if len(parsedRepo) != 2 {
+			fatal("--repo (in the form ownerName/repoName) is required to find the app's installation without --install_id")
+		}
+		if clientConfiguration.InstallationID, err = github.DiscoverInstallationID(clientConfiguration, parsedRepo[0], parsedRepo[1]); err != nil {
+			fatal(errors.Wrap(err, "failed during setup"))
+		}
+	}
 
-	tool, results, err := sarif.ParseFromFile(*sarifPath)
+	if *sourceRoot == "" {
+		if *sourceRoot = os.Getenv("GITHUB_WORKSPACE"); *sourceRoot == "" {
+			*sourceRoot, _ = os.Getwd()
+		}
+	}
+
+	var configurationErrors []string
+	if *configPath != "" && len(parsedRepo) == 2 {
+		source, err := parseConfigSource(*configSourceFlag)
+		if err != nil {
+			fatal(err)
+		}
+		content, err := readRepositoryConfig(source, *configPath, *sourceRoot, clientConfiguration, parsedRepo[0], parsedRepo[1], *sha)
+		if err != nil {
+			log.Printf("Warning: %v; continuing without the repository's configuration.\n", err)
+		} else if content != nil {
+			config, problems := parseRepositoryConfig(content)
+			if len(problems) > 0 {
+				log.Printf("Warning: ignoring the invalid configuration in %q: %s\n", *configPath, strings.Join(problems, "; "))
+				configurationErrors = problems
+			} else if err := config.apply(flag.CommandLine); err != nil {
+				fatal(err)
+			}
+		}
+	}
+
+	switch command {
+	case "start", "fail":
+		checker, err := github.CreateCommitChecker(clientConfiguration, parsedRepo[0], parsedRepo[1], *sha)
+		if err != nil {
+			fatal(errors.Wrap(err, "failed during setup"))
+		}
+		if command == "start" {
+			err = startCheckRun(checker, *sha, *checkNameOverride, *externalID, *statePath)
+		} else {
+			err = failCheckRuns(checker, *sha, *checkNameOverride, *externalID, *statePath, *errorPath)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return
+	case "finish", "":
+	default:
+		fatal(fmt.Sprintf("unknown command %q (must be one of start, finish, or fail)", command))
+	}
+
+	state, err := loadRunState(*statePath)
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed to load sarif file"))
+		fatal(err)
 	}
+	forgetOtherCommits(state, *sha)
 
-	if len(results) == 0 {
-		log.Println("No findings to post.")
+	policy, err := parseLocationPolicy(*locationPolicyFlag)
+	if err != nil {
+		fatal(err)
+	}
+	existingCheckRunPolicy, err := github.ParseExistingCheckRunPolicy(*existingCheckRunsFlag)
+	if err != nil {
+		fatal(err)
+	}
+	diffSource, err := github.ParseDiffSource(*diffSourceFlag)
+	if err != nil {
+		fatal(err)
+	}
+	filterGranularity, err := github.ParseFilterGranularity(*filterGranularityFlag)
+	if err != nil {
+		fatal(err)
+	}
+	unpatchedFilePolicy, err := github.ParseUnpatchedFilePolicy(*unpatchedFilesFlag)
+	if err != nil {
+		fatal(err)
+	}
+	if *addedLineContext < 0 {
+		fatal("--added_line_context must not be negative")
+	}
+
+	failOn, err := github.ParseFailureLevel(*failOnFlag)
+	if err != nil {
+		fatal(err)
+	}
+	noFindingsConclusion, err := github.ParseNoFindingsConclusion(*noFindingsConclusionFlag)
+	if err != nil {
+		fatal(err)
+	}
+	conclusionPolicy := github.ConclusionPolicy{
+		FailOn:      failOn,
+		MaxErrors:   optionalCount(*maxErrors),
+		MaxWarnings: optionalCount(*maxWarnings),
+		MaxNotes:    optionalCount(*maxNotes),
+		NoFindings:  noFindingsConclusion,
+	}
+
+	severities, err := parseSeverityMapping(*securitySeverityError, *securitySeverityWarning, ruleLevels, toolLevels, *defaultLevel)
+	if err != nil {
+		fatal(err)
+	}
+
+	paths, err := expandSarifPaths(sarifPaths)
+	if err != nil {
+		fatal(errors.Wrap(err, "failed to find sarif files"))
+	}
+	if len(paths) == 0 {
+		fatal("at least one --sarif_path is required")
+	}
+
+	runs, err := parseSarifFiles(paths)
+	if err != nil {
+		fatal(errors.Wrap(err, "failed to load sarif files"))
+	}
+
+	severities.mapRuns(runs)
+	mapper := newPathMapper(*sourceRoot, stripPathPrefixes, *addPathPrefix)
+	if unmapped := mapper.mapRuns(runs); unmapped > 0 {
+		log.Printf("Warning: the paths of %d results could not be mapped into the repository (see --source_root, --strip_path_prefix, and --add_path_prefix); they will not be annotated.\n", unmapped)
+	}
+	if ignored := ignoreResults(runs, ignorePaths); ignored > 0 {
+		log.Printf("Ignored %d results in paths matching --ignore_path.\n", ignored)
+	}
+
+	baselineResults := make(map[string][]*sarif.Result)
+	if len(baselineSarifPaths) > 0 {
+		baselinePaths, err := expandSarifPaths(baselineSarifPaths)
+		if err != nil {
+			fatal(errors.Wrap(err, "failed to find baseline sarif files"))
+		}
+		baselineRuns, err := parseSarifFiles(baselinePaths)
+		if err != nil {
+			fatal(errors.Wrap(err, "failed to load baseline sarif files"))
+		}
+		severities.mapRuns(baselineRuns)
+		mapper.mapRuns(baselineRuns)
+		ignoreResults(baselineRuns, ignorePaths)
+		for _, baselineCheck := range runsToChecks(baselineRuns, *mergeRuns, *checkNameOverride) {
+			baselineResults[baselineCheck.name] = baselineCheck.results
+		}
+	}
+
+	allChecks := runsToChecks(runs, *mergeRuns, *checkNameOverride)
+	if len(allChecks) == 0 && *checkNameOverride != "" && noFindingsConclusion != github.NoFindingsSkip {
+		// empty sarif has no tool to name a check after, so a check without findings needs --check_name
+		allChecks = []*check{{name: *checkNameOverride}}
+	}
+	startedCheckRuns := make(map[*check]startedCheckRun)
+	if command == "finish" {
+		startedCheckRuns = state.startedCheckRuns(allChecks)
+	}
+	var checks []*check
+	for _, check := range allChecks {
+		if _, ok := startedCheckRuns[check]; ok {
+			// a started check run must be completed, even without findings
+			checks = append(checks, check)
+			continue
+		}
+		if len(check.results) == 0 && noFindingsConclusion == github.NoFindingsSkip {
+			log.Printf("No findings to post for %s.\n", check.name)
+			continue
+		}
+		checks = append(checks, check)
+	}
+
+	if len(checks) == 0 && (command != "finish" || len(state.CheckRuns) == 0) {
+		if len(allChecks) == 0 && noFindingsConclusion != github.NoFindingsSkip {
+			log.Println("No findings to post, and no tool to name a check after (set --check_name to post a check without findings).")
+		} else {
+			log.Println("No findings to post.")
+		}
 		return
 	}
 
 	annotator, err := github.CreatePullRequestAnnotator(
-		github.ClientConfiguration{AppID: int64(*appID), InstallationID: int64(*installID), AppKeyPath: *appKeyPath},
-		github.PullRequestConfiguration{Owner: parsedRepo[0], Repo: parsedRepo[1], Number: *prNumber},
+		clientConfiguration,
+		github.PullRequestConfiguration{Owner: parsedRepo[0], Repo: parsedRepo[1], Number: *prNumber, DiffSource: diffSource, GitDirectory: *gitDirectory, TranslateToHead: *translateToHead},
 		*sha,
 	)
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed during setup"))
+		fatal(errors.Wrap(err, "failed during setup"))
 	}
 
-	annotations, err := resultsToAnnotations(results)
-	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed to convert results to annotations"))
+	var failedChecks []string
+	for _, check := range checks {
+		// a started check run keeps its name, which a required status check may refer to
+		checkName := check.name
+		started, ok := startedCheckRuns[check]
+		if ok {
+			checkName = started.name
+		}
+		annotations, details := checkToAnnotations(check, baselineResults[check.name], policy)
+		details.ConfigurationErrors = configurationErrors
+		if len(details.UnannotatedFindings) > 0 {
+			log.Printf("%d findings for %s could not be annotated and will be listed in the check summary.\n", len(details.UnannotatedFindings), checkName)
+		}
+		configuration := github.CheckRunConfiguration{
+			Name:                     checkName,
+			FilterAnnotations:        *filterAnnotations,
+			Filter:                   github.LineFilter{Granularity: filterGranularity, AddedLineContext: *addedLineContext, UnpatchedFiles: unpatchedFilePolicy},
+			AnnotateStartLineOnly:    *annotateStartLineOnly,
+			AnnotateRelatedLocations: *annotateRelatedLocations,
+			ExistingCheckRuns:        existingCheckRunPolicy,
+			ExternalID:               *externalID,
+			CheckRunID:               started.id,
+			Conclusion:               conclusionPolicy,
+			Resume:                   state.resumeFrom(checkName),
+			OnProgress:               recordProgress(state, checkName, *statePath),
+		}
+		conclusion, err := annotator.PostAnnotations(annotations, details, configuration)
+		if err != nil {
+			log.Printf("Run again with the same --state_path to resume posting %s.\n", checkName)
+			fatal(errors.Wrapf(err, "failed to post annotations for %s", checkName))
+		}
+		if conclusion == "failure" {
+			failedChecks = append(failedChecks, checkName)
+		}
+
+		if *postSuggestions && !state.Progress[checkName].Suggested {
+			suggestions := resultsToSuggestions(check.results)
+			if len(suggestions) == 0 {
+				continue
+			}
+			posted, err := annotator.PostSuggestions(suggestions, fmt.Sprintf("Suggested fixes from %s.", check.name))
+			if err != nil {
+				log.Printf("Run again with the same --state_path to resume posting %s.\n", checkName)
+				fatal(errors.Wrapf(err, "failed to post suggestions for %s", checkName))
+			}
+			log.Printf("Posted %d of %d suggestions for %s.\n", posted, len(suggestions), checkName)
+			if progress, ok := state.Progress[checkName]; ok {
+				progress.Suggested = true
+				state.Progress[checkName] = progress
+				saveProgress(state, *statePath)
+			}
+		}
+	}
+
+	if command == "finish" {
+		completedCheckRuns := make(map[string]bool)
+		for _, started := range startedCheckRuns {
+			completedCheckRuns[started.name] = true
+		}
+		// check runs started for checks which reported nothing (e.g., the tool was renamed) would otherwise stay in progress
+		for name, id := range state.CheckRuns {
+			if completedCheckRuns[name] {
+				continue
+			}
+			log.Printf("No results were reported for the check run started for %s, so it is marked as failed.\n", name)
+			if err := annotator.FailUnreportedCheckRun(id, name); err != nil {
+				fatal(errors.Wrapf(err, "failed to complete the check run started for %s", name))
+			}
+		}
+		state.CheckRuns = make(map[string]int64)
 	}
+	// every check was posted, so there is nothing left to resume
+	state.Progress = make(map[string]checkProgress)
+	if err := state.saveOrRemove(*statePath); err != nil {
+		fatal(err)
+	}
+
+	if len(failedChecks) > 0 {
+		log.Printf("%s concluded with a failure.\n", strings.Join(failedChecks, ", "))
+		if *exitOnFailure {
+			log.Println(clientConfiguration.Stats)
+			os.Exit(2)
+		}
+	}
+}
 
-	checkName := tool.Name
-	if *checkNameOverride != "" {
-		checkName = *checkNameOverride
+// Record how far posting a check got in the state file, so that running again after a failure resumes posting it.
+func recordProgress(state *runState, checkName string, statePath string) func(github.PostingProgress) error {
+	return func(progress github.PostingProgress) error {
+		// suggestions are posted after every annotation, so any new progress means they are not yet posted
+		state.Progress[checkName] = checkProgress{PostingProgress: progress}
+		saveProgress(state, statePath)
+		return nil
 	}
+}
+
+// Save the state, warning rather than failing when it cannot be saved, as posting can continue without it (but will
+// not resume).
+func saveProgress(state *runState, statePath string) {
+	if err := state.save(statePath); err != nil {
+		log.Printf("Warning: %v; a failed run will not resume.\n", err)
+	}
+}
 
-	if err := annotator.PostAnnotations(annotations, checkName, *filterAnnotations, *annotateStartLineOnly); err != nil {
-		log.Fatal(errors.Wrap(err, "failed to post annotations"))
+// A maximum count from a flag, where negative values mean the maximum is unset.
+func optionalCount(count int) *int {
+	if count < 0 {
+		return nil
 	}
+	return &count
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"less-advanced-security/sarif"
+	"path"
+	"strings"
+)
+
+// Maps the paths of sarif results to paths relative to the root of the repository.
+type pathMapper struct {
+	// the absolute path the repository was checked out to for the scan
+	sourceRoot string
+	// prefixes removed from relative paths (e.g., when a scan of a monorepo reports paths relative to a parent directory)
+	stripPrefixes []string
+	// a prefix added to relative paths (e.g., when a scan ran inside a subdirectory of the repository)
+	addPrefix string
+}
+
+func newPathMapper(sourceRoot string, stripPrefixes []string, addPrefix string) pathMapper {
+	mapper := pathMapper{sourceRoot: cleanPath(sourceRoot), addPrefix: cleanPath(addPrefix)}
+	for _, prefix := range stripPrefixes {
+		if prefix = cleanPath(prefix); prefix != "" {
+			mapper.stripPrefixes = append(mapper.stripPrefixes, prefix)
+		}
+	}
+	return mapper
+}
+
+// Map a path from a sarif result into the repository, reporting whether it could be mapped. Absolute paths must be
+// inside the source root, and no path may point outside the repository.
+func (mapper pathMapper) mapPath(filepath string) (string, bool) {
+	original := filepath
+	if sarif.IsAbsolutePath(filepath) {
+		if mapper.sourceRoot == "" || !strings.HasPrefix(filepath, mapper.sourceRoot+"/") {
+			return original, false
+		}
+		filepath = strings.TrimPrefix(filepath, mapper.sourceRoot+"/")
+	}
+
+	for _, prefix := range mapper.stripPrefixes {
+		if strings.HasPrefix(filepath, prefix+"/") {
+			filepath = strings.TrimPrefix(filepath, prefix+"/")
+			break
+		}
+	}
+	if mapper.addPrefix != "" {
+		filepath = path.Join(mapper.addPrefix, filepath)
+	}
+
+	if filepath == "" || filepath == ".." || strings.HasPrefix(filepath, "../") {
+		return original, false
+	}
+	return filepath, true
+}
+
+// Map the paths of every location in the runs into the repository, returning the number of results whose primary
+// location could not be mapped. Paths which cannot be mapped are left unchanged.
+func (mapper pathMapper) mapRuns(runs []*sarif.Run) (unmapped int) {
+	mapLocations := func(locations []sarif.ResultLocation) bool {
+		mapped := true
+		for i := range locations {
+			var ok bool
+			locations[i].Filepath, ok = mapper.mapPath(locations[i].Filepath)
+			mapped = mapped && ok
+		}
+		return mapped
+	}
+
+	for _, run := range runs {
+		for _, result := range run.Results {
+			if !mapLocations(result.Locations) {
+				unmapped += 1
+			}
+			mapLocations(result.RelatedLocations)
+			for _, codeFlow := range result.CodeFlows {
+				mapLocations(codeFlow.Steps)
+			}
+			for _, fix := range result.Fixes {
+				for i := range fix.Changes {
+					fix.Changes[i].Filepath, _ = mapper.mapPath(fix.Changes[i].Filepath)
+				}
+			}
+		}
+	}
+	return unmapped
+}
+
+// Clean a path from a flag, using forward slashes and no trailing slash.
+func cleanPath(filepath string) string {
+	filepath = strings.ReplaceAll(filepath, "\\", "/")
+	if filepath == "" {
+		return ""
+	}
+	if cleaned := path.Clean(filepath); cleaned != "." && cleaned != "/" {
+		return cleaned
+	}
+	return ""
+}
+
+// Remove the results whose primary location matches any of the patterns (see matchesPathPattern), returning the
+// number removed.
+func ignoreResults(runs []*sarif.Run, patterns []string) (ignored int) {
+	if len(patterns) == 0 {
+		return 0
+	}
+	for _, run := range runs {
+		kept := []*sarif.Result{}
+		for _, result := range run.Results {
+			if len(result.Locations) > 0 && matchesAnyPathPattern(patterns, result.Locations[0].Filepath) {
+				ignored += 1
+				continue
+			}
+			kept = append(kept, result)
+		}
+		run.Results = kept
+	}
+	return ignored
+}
+
+func matchesAnyPathPattern(patterns []string, filepath string) bool {
+	for _, pattern := range patterns {
+		if matchesPathPattern(pattern, filepath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a path matches a pattern, in the manner of .gitignore: a pattern ending in / (or /**) matches everything in
+// a directory, a pattern without a slash matches any file or directory name in the path, and other patterns are globs
+// matched against the whole path (e.g., test/*_test.go).
+func matchesPathPattern(pattern string, filepath string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") || strings.HasSuffix(pattern, "/**") {
+		directory := strings.TrimSuffix(strings.TrimSuffix(pattern, "**"), "/")
+		segments := strings.Split(filepath, "/")
+		for i := 1; i < len(segments); i++ {
+			if matchesPathPattern(directory, strings.Join(segments[:i], "/")) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !strings.Contains(pattern, "/") {
+		for _, segment := range strings.Split(filepath, "/") {
+			if matched, _ := path.Match(pattern, segment); matched {
+				return true
+			}
+		}
+		return false
+	}
+	matched, _ := path.Match(pattern, filepath)
+	return matched
+}
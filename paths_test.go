@@ -0,0 +1,100 @@
+package main
+
+import (
+	"less-advanced-security/sarif"
+	"testing"
+)
+
+func TestPathMapperMapPath(t *testing.T) {
+	tests := []struct {
+		name           string
+		mapper         pathMapper
+		path, expected string
+		expectedOK     bool
+	}{
+		{"relative path", newPathMapper("/work/app", nil, ""), "src/x.py", "src/x.py", true},
+		{"absolute path inside source root", newPathMapper("/work/app/", nil, ""), "/work/app/src/x.py", "src/x.py", true},
+		{"absolute path outside source root", newPathMapper("/work/app", nil, ""), "/work/other/src/x.py", "/work/other/src/x.py", false},
+		{"absolute path sharing a prefix with the source root", newPathMapper("/work/app", nil, ""), "/work/application/x.py", "/work/application/x.py", false},
+		{"absolute path without source root", newPathMapper("", nil, ""), "/work/app/src/x.py", "/work/app/src/x.py", false},
+		{"windows source root", newPathMapper("C:\\work\\app", nil, ""), "C:/work/app/src/x.py", "src/x.py", true},
+		{"stripped prefix", newPathMapper("/work/app", []string{"other/", "monorepo"}, ""), "monorepo/src/x.py", "src/x.py", true},
+		{"stripped prefix after source root", newPathMapper("/work", []string{"app"}, ""), "/work/app/src/x.py", "src/x.py", true},
+		{"added prefix", newPathMapper("/work/app", nil, "services/api/"), "src/x.py", "services/api/src/x.py", true},
+		{"path outside repository", newPathMapper("/work/app", nil, ""), "../x.py", "../x.py", false},
+		{"path leaving added prefix", newPathMapper("/work/app", nil, "services/api"), "../../../x.py", "../../../x.py", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.mapper.mapPath(tt.path)
+			if got != tt.expected || ok != tt.expectedOK {
+				t.Errorf("expected %q (%t) but got %q (%t)", tt.expected, tt.expectedOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestPathMapperMapRuns(t *testing.T) {
+	one := 1
+	mapped := &sarif.Result{
+		Locations:        []sarif.ResultLocation{{Filepath: "/work/app/src/x.py", StartLine: &one}},
+		RelatedLocations: []sarif.ResultLocation{{Filepath: "/work/app/src/y.py"}},
+		CodeFlows:        []sarif.CodeFlow{{Steps: []sarif.ResultLocation{{Filepath: "/work/app/src/z.py"}}}},
+		Fixes:            []sarif.Fix{{Changes: []sarif.FileChange{{Filepath: "/work/app/src/x.py"}}}},
+	}
+	unmapped := &sarif.Result{Locations: []sarif.ResultLocation{{Filepath: "/tmp/generated.py", StartLine: &one}}}
+	withoutLocation := &sarif.Result{}
+
+	count := newPathMapper("/work/app", nil, "").mapRuns([]*sarif.Run{{Results: []*sarif.Result{mapped, unmapped, withoutLocation}}})
+	if count != 1 {
+		t.Errorf("expected 1 unmapped result but got %d", count)
+	}
+	paths := []string{mapped.Locations[0].Filepath, mapped.RelatedLocations[0].Filepath, mapped.CodeFlows[0].Steps[0].Filepath, mapped.Fixes[0].Changes[0].Filepath}
+	for i, expected := range []string{"src/x.py", "src/y.py", "src/z.py", "src/x.py"} {
+		if paths[i] != expected {
+			t.Errorf("expected path %q but got %q", expected, paths[i])
+		}
+	}
+	if unmapped.Locations[0].Filepath != "/tmp/generated.py" {
+		t.Errorf("expected the unmapped path to be unchanged but got %q", unmapped.Locations[0].Filepath)
+	}
+}
+
+func TestMatchesPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		expected      bool
+	}{
+		{"vendor/", "vendor/lib/x.go", true},
+		{"vendor/", "src/vendor/x.go", true},
+		{"vendor/", "vendors/x.go", false},
+		{"vendor/**", "vendor/x.go", true},
+		{"src/generated/", "src/generated/api/x.go", true},
+		{"src/generated/", "lib/src/generated/x.go", false},
+		{"*.min.js", "static/js/app.min.js", true},
+		{"*.min.js", "static/js/app.js", false},
+		{"test/*_test.go", "test/x_test.go", true},
+		{"test/*_test.go", "test/unit/x_test.go", false},
+		{"/docs/index.md", "docs/index.md", true},
+		{"x.go", "x.go", true},
+	}
+	for _, tt := range tests {
+		if got := matchesPathPattern(tt.pattern, tt.path); got != tt.expected {
+			t.Errorf("expected %q matching %q to be %t", tt.pattern, tt.path, tt.expected)
+		}
+	}
+}
+
+func TestIgnoreResults(t *testing.T) {
+	vendored := &sarif.Result{Locations: []sarif.ResultLocation{{Filepath: "vendor/lib/x.go"}}}
+	kept := &sarif.Result{Locations: []sarif.ResultLocation{{Filepath: "src/x.go"}}}
+	withoutLocation := &sarif.Result{}
+	run := &sarif.Run{Results: []*sarif.Result{vendored, kept, withoutLocation}}
+
+	if ignored := ignoreResults([]*sarif.Run{run}, []string{"vendor/"}); ignored != 1 {
+		t.Errorf("expected 1 ignored result but got %d", ignored)
+	}
+	if len(run.Results) != 2 || run.Results[0] != kept || run.Results[1] != withoutLocation {
+		t.Errorf("expected the results outside vendor/ to be kept but got %v", run.Results)
+	}
+}
@@ -0,0 +1,182 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// The sarif library does not model every field we use. The raw types below decode those fields directly from the
+// report, and are indexed in parallel with the library's runs and results.
+
+type rawReport struct {
+	Runs []rawRun `json:"runs"`
+}
+
+type rawRun struct {
+	Tool struct {
+		Driver struct {
+			Rules []rawRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []rawResult `json:"results"`
+	// locations which uriBaseIds in the run refer to (e.g., %SRCROOT%)
+	OriginalURIBaseIDs map[string]rawArtifactLocation `json:"originalUriBaseIds"`
+}
+
+type rawArtifactLocation struct {
+	URI       *string `json:"uri"`
+	URIBaseID *string `json:"uriBaseId"`
+}
+
+type rawRule struct {
+	ID         string        `json:"id"`
+	Properties rawProperties `json:"properties"`
+}
+
+type rawResult struct {
+	CodeFlows  []rawCodeFlow `json:"codeFlows"`
+	Properties rawProperties `json:"properties"`
+}
+
+type rawProperties map[string]interface{}
+
+type rawCodeFlow struct {
+	Message     *rawMessage     `json:"message"`
+	ThreadFlows []rawThreadFlow `json:"threadFlows"`
+}
+
+type rawThreadFlow struct {
+	Message   *rawMessage             `json:"message"`
+	Locations []rawThreadFlowLocation `json:"locations"`
+}
+
+type rawThreadFlowLocation struct {
+	Location *rawLocation `json:"location"`
+}
+
+type rawLocation struct {
+	PhysicalLocation *struct {
+		ArtifactLocation *rawArtifactLocation `json:"artifactLocation"`
+		Region           *struct {
+			StartLine   *int `json:"startLine"`
+			EndLine     *int `json:"endLine"`
+			StartColumn *int `json:"startColumn"`
+			EndColumn   *int `json:"endColumn"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+	Message *rawMessage `json:"message"`
+}
+
+type rawMessage struct {
+	Text *string `json:"text"`
+}
+
+func (m *rawMessage) text() string {
+	if m == nil || m.Text == nil {
+		return ""
+	}
+	return *m.Text
+}
+
+func (properties rawProperties) string(key string) string {
+	value, found := properties[key]
+	if !found || value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Parse the security-severity property (e.g., a CVSS score), which tools report as either a string or a number.
+func (properties rawProperties) securitySeverity() *float64 {
+	switch value := properties["security-severity"].(type) {
+	case float64:
+		return &value
+	case string:
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
+
+func parseRawReport(content []byte) (*rawReport, error) {
+	var report rawReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, errors.Wrap(err, "failed to decode sarif file")
+	}
+	return &report, nil
+}
+
+// Returns the raw result at the given indices, or an empty result if it does not exist.
+func (report *rawReport) result(runIndex, resultIndex int) rawResult {
+	if report == nil || runIndex >= len(report.Runs) || resultIndex >= len(report.Runs[runIndex].Results) {
+		return rawResult{}
+	}
+	return report.Runs[runIndex].Results[resultIndex]
+}
+
+// Returns the properties of each rule in the run at the given index, by rule id.
+func (report *rawReport) ruleProperties(runIndex int) map[string]rawProperties {
+	ruleIDToProperties := make(map[string]rawProperties)
+	if report == nil || runIndex >= len(report.Runs) {
+		return ruleIDToProperties
+	}
+	for _, rule := range report.Runs[runIndex].Tool.Driver.Rules {
+		ruleIDToProperties[rule.ID] = rule.Properties
+	}
+	return ruleIDToProperties
+}
+
+// Returns the resolver for URIs in the run at the given index.
+func (report *rawReport) uriResolver(runIndex int) uriResolver {
+	if report == nil || runIndex >= len(report.Runs) {
+		return uriResolver{}
+	}
+	return uriResolver{baseIDs: report.Runs[runIndex].OriginalURIBaseIDs}
+}
+
+// Flatten each thread flow of each code flow into an ordered list of steps.
+func (result rawResult) codeFlows(resolver uriResolver) []CodeFlow {
+	var codeFlows []CodeFlow
+	for _, codeFlow := range result.CodeFlows {
+		for _, threadFlow := range codeFlow.ThreadFlows {
+			message := threadFlow.Message.text()
+			if message == "" {
+				message = codeFlow.Message.text()
+			}
+
+			flow := CodeFlow{Message: message}
+			for _, threadFlowLocation := range threadFlow.Locations {
+				if step, ok := threadFlowLocation.Location.toResultLocation(resolver); ok {
+					flow.Steps = append(flow.Steps, step)
+				}
+			}
+			if len(flow.Steps) > 0 {
+				codeFlows = append(codeFlows, flow)
+			}
+		}
+	}
+	return codeFlows
+}
+
+func (location *rawLocation) toResultLocation(resolver uriResolver) (ResultLocation, bool) {
+	if location == nil || location.PhysicalLocation == nil || location.PhysicalLocation.ArtifactLocation == nil || location.PhysicalLocation.ArtifactLocation.URI == nil {
+		return ResultLocation{}, false
+	}
+
+	artifactLocation := location.PhysicalLocation.ArtifactLocation
+	resultLocation := ResultLocation{
+		Filepath: resolver.resolve(*artifactLocation.URI, artifactLocation.URIBaseID),
+		Message:  location.Message.text(),
+	}
+	if location.PhysicalLocation.Region != nil {
+		resultLocation.StartLine = location.PhysicalLocation.Region.StartLine
+		resultLocation.EndLine = location.PhysicalLocation.Region.EndLine
+		resultLocation.StartColumn = location.PhysicalLocation.Region.StartColumn
+		resultLocation.EndColumn = location.PhysicalLocation.Region.EndColumn
+	}
+	return resultLocation, true
+}
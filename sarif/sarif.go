@@ -12,41 +12,121 @@ import (
 type Tool struct {
 	Name    string
 	Version *string
+	Rules   []Rule
+}
+
+type Rule struct {
+	ID, Name         string
+	ShortDescription string
+	HelpURI          string
 }
 
 type Result struct {
-	Message   string
-	RuleID    string
-	Locations []ResultLocation
-	Raw       string
-	Level     string
+	Message          string
+	RuleID           string
+	Locations        []ResultLocation
+	RelatedLocations []ResultLocation
+	CodeFlows        []CodeFlow
+	Fixes            []Fix
+	// Fingerprints which identify the result across commits (e.g., for baseline comparison), keyed by their type
+	PartialFingerprints map[string]string
+	Raw                 string
+	Level               string
+	// The security-severity property of the result (or its rule), usually a CVSS score from 0.0 to 10.0
+	SecuritySeverity *float64
+	// The problem.severity property of the result (or its rule), one of error, warning, or recommendation
+	ProblemSeverity string
 }
 
 type ResultLocation struct {
+	// The location's path, decoded from its URI and resolved against its uriBaseId. Paths are relative unless the URI
+	// (or its base) is absolute, and always use forward slashes.
 	Filepath           string
 	StartLine, EndLine *int
+	// One-indexed columns of the region, if reported. The end column is exclusive.
+	StartColumn, EndColumn *int
+	Message                string
+	// The source code of the region, if reported
+	Snippet string
+}
+
+// A CodeFlow is an ordered list of steps through the code (e.g., the path from a taint source to its sink). Each
+// sarif threadFlow is parsed as its own CodeFlow.
+type CodeFlow struct {
+	Message string
+	Steps   []ResultLocation
+}
+
+// A Run pairs the tool which produced a set of results with those results. A
+// single sarif file may contain many runs (e.g., merged multi-tool output).
+type Run struct {
+	Tool    *Tool
+	Results []*Result
+}
+
+// A Fix is a proposed change to one or more files which resolves a result.
+type Fix struct {
+	Description string
+	Changes     []FileChange
+}
+
+type FileChange struct {
+	Filepath     string
+	Replacements []Replacement
+}
+
+// A Replacement deletes a region of a file and inserts text in its place. Lines and columns are one-indexed, and the
+// end column is exclusive. Columns are nil when the region starts at the beginning (or ends at the end) of a line.
+type Replacement struct {
+	StartLine, EndLine     int
+	StartColumn, EndColumn *int
+	InsertedText           string
 }
 
-func ParseFromFile(path string) (*Tool, []*Result, error) {
+func ParseFromFile(path string) ([]*Run, error) {
 	if _, err := os.Stat(path); err != nil && os.IsNotExist(err) {
-		return nil, nil, errors.Errorf("no file exists at %q", path)
+		return nil, errors.Errorf("no file exists at %q", path)
 	}
 
 	// Files which _exist_ but are _empty_ should be treated as if they contained {}
 	if info, _ := os.Stat(path); info.Size() == 0 {
-		return nil, nil, nil
+		return nil, nil
 	}
 
-	report, err := sarif.Open(path)
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "failed to load sarif file")
+		return nil, errors.Wrap(err, "failed to read sarif file")
 	}
 
-	if len(report.Runs) > 1 {
-		return nil, nil, errors.Errorf("cannot parse more than 1 run, found %d", len(report.Runs))
+	report, err := sarif.FromBytes(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sarif file")
 	}
 
-	run := report.Runs[0]
+	rawReport, err := parseRawReport(content)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*Run{}
+	for i, run := range report.Runs {
+		if run == nil {
+			continue
+		}
+		parsedRun, err := parseRun(run, rawReport, i)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse run %d", i)
+		}
+		runs = append(runs, parsedRun)
+	}
+
+	return runs, nil
+}
+
+func parseRun(run *sarif.Run, rawReport *rawReport, runIndex int) (*Run, error) {
+	if run.Tool.Driver == nil {
+		return nil, errors.New("run has no tool driver")
+	}
 
 	tool := Tool{
 		Name:    run.Tool.Driver.Name,
@@ -55,37 +135,36 @@ func ParseFromFile(path string) (*Tool, []*Result, error) {
 
 	ruleid_to_level := map[string]string{}
 	for _, rule := range run.Tool.Driver.Rules {
+		if rule == nil {
+			continue
+		}
 		if rule.DefaultConfiguration != nil && rule.DefaultConfiguration.Level != nil {
 			ruleid_to_level[rule.ID] = fmt.Sprintf("%v", rule.DefaultConfiguration.Level)
 		}
+		tool.Rules = append(tool.Rules, parseRule(rule))
 	}
 
+	resolver := rawReport.uriResolver(runIndex)
+	ruleIDToProperties := rawReport.ruleProperties(runIndex)
 	results := []*Result{}
-	for _, result := range run.Results {
+	for resultIndex, result := range run.Results {
 		if len(result.Suppressions) > 0 {
 			continue
 		}
+		rawResult := rawReport.result(runIndex, resultIndex)
+		ruleProperties := ruleIDToProperties[*result.RuleID]
 
-		raw, _ := json.Marshal(result)
-
-		var locations []ResultLocation
-		for _, location := range result.Locations {
-			if location.PhysicalLocation == nil || location.PhysicalLocation.ArtifactLocation == nil {
-				continue
-			}
-
-			var startLine, endLine *int
-			if location.PhysicalLocation.Region != nil {
-				startLine = location.PhysicalLocation.Region.StartLine
-				endLine = location.PhysicalLocation.Region.EndLine
-			}
-			locations = append(locations, ResultLocation{
-				Filepath:  *location.PhysicalLocation.ArtifactLocation.URI,
-				StartLine: startLine,
-				EndLine:   endLine,
-			})
+		securitySeverity := rawResult.Properties.securitySeverity()
+		if securitySeverity == nil {
+			securitySeverity = ruleProperties.securitySeverity()
+		}
+		problemSeverity := rawResult.Properties.string("problem.severity")
+		if problemSeverity == "" {
+			problemSeverity = ruleProperties.string("problem.severity")
 		}
 
+		raw, _ := json.Marshal(result)
+
 		var level string
 		if result.Level != nil {
 			level = *result.Level
@@ -94,14 +173,135 @@ func ParseFromFile(path string) (*Tool, []*Result, error) {
 		}
 
 		results = append(results, &Result{
-			Message:   *result.Message.Text,
-			RuleID:    *result.RuleID,
-			Raw:       string(raw),
-			Locations: locations,
-			Level:     level,
+			Message:             *result.Message.Text,
+			RuleID:              *result.RuleID,
+			Raw:                 string(raw),
+			Locations:           parseLocations(result.Locations, resolver),
+			RelatedLocations:    parseLocations(result.RelatedLocations, resolver),
+			CodeFlows:           rawResult.codeFlows(resolver),
+			Fixes:               parseFixes(result.Fixes, resolver),
+			Level:               level,
+			PartialFingerprints: parseFingerprints(result.PartialFingerprints),
+			SecuritySeverity:    securitySeverity,
+			ProblemSeverity:     problemSeverity,
 		})
 
 	}
 
-	return &tool, results, nil
+	return &Run{Tool: &tool, Results: results}, nil
+}
+
+func parseRule(rule *sarif.ReportingDescriptor) Rule {
+	parsed := Rule{ID: rule.ID}
+	if rule.Name != nil {
+		parsed.Name = *rule.Name
+	}
+	if rule.ShortDescription != nil {
+		if rule.ShortDescription.Text != nil {
+			parsed.ShortDescription = *rule.ShortDescription.Text
+		} else if rule.ShortDescription.Markdown != nil {
+			parsed.ShortDescription = *rule.ShortDescription.Markdown
+		}
+	}
+	if rule.HelpURI != nil {
+		parsed.HelpURI = *rule.HelpURI
+	}
+	return parsed
+}
+
+func parseLocations(sarifLocations []*sarif.Location, resolver uriResolver) []ResultLocation {
+	var locations []ResultLocation
+	for _, location := range sarifLocations {
+		if location == nil || location.PhysicalLocation == nil || location.PhysicalLocation.ArtifactLocation == nil || location.PhysicalLocation.ArtifactLocation.URI == nil {
+			continue
+		}
+
+		var startLine, endLine, startColumn, endColumn *int
+		var snippet string
+		if region := location.PhysicalLocation.Region; region != nil {
+			startLine = region.StartLine
+			endLine = region.EndLine
+			startColumn = region.StartColumn
+			endColumn = region.EndColumn
+			if region.Snippet != nil && region.Snippet.Text != nil {
+				snippet = *region.Snippet.Text
+			}
+		}
+		var message string
+		if location.Message != nil && location.Message.Text != nil {
+			message = *location.Message.Text
+		}
+		locations = append(locations, ResultLocation{
+			Filepath:    resolver.resolve(*location.PhysicalLocation.ArtifactLocation.URI, location.PhysicalLocation.ArtifactLocation.URIBaseId),
+			StartLine:   startLine,
+			EndLine:     endLine,
+			StartColumn: startColumn,
+			EndColumn:   endColumn,
+			Message:     message,
+			Snippet:     snippet,
+		})
+	}
+	return locations
+}
+
+func parseFingerprints(fingerprints map[string]interface{}) map[string]string {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	parsed := make(map[string]string, len(fingerprints))
+	for key, value := range fingerprints {
+		parsed[key] = fmt.Sprintf("%v", value)
+	}
+	return parsed
+}
+
+// Parse fixes, dropping any replacements which do not identify the lines they delete.
+func parseFixes(sarifFixes []*sarif.Fix, resolver uriResolver) []Fix {
+	var fixes []Fix
+	for _, sarifFix := range sarifFixes {
+		if sarifFix == nil {
+			continue
+		}
+
+		fix := Fix{}
+		if sarifFix.Description != nil && sarifFix.Description.Text != nil {
+			fix.Description = *sarifFix.Description.Text
+		}
+		for _, artifactChange := range sarifFix.ArtifactChanges {
+			if artifactChange == nil || artifactChange.ArtifactLocation.URI == nil {
+				continue
+			}
+
+			change := FileChange{Filepath: resolver.resolve(*artifactChange.ArtifactLocation.URI, artifactChange.ArtifactLocation.URIBaseId)}
+			for _, replacement := range artifactChange.Replacements {
+				if replacement == nil || replacement.DeletedRegion.StartLine == nil {
+					continue
+				}
+				region := replacement.DeletedRegion
+
+				endLine := *region.StartLine
+				if region.EndLine != nil {
+					endLine = *region.EndLine
+				}
+				var insertedText string
+				if replacement.InsertedContent != nil && replacement.InsertedContent.Text != nil {
+					insertedText = *replacement.InsertedContent.Text
+				}
+				change.Replacements = append(change.Replacements, Replacement{
+					StartLine:    *region.StartLine,
+					EndLine:      endLine,
+					StartColumn:  region.StartColumn,
+					EndColumn:    region.EndColumn,
+					InsertedText: insertedText,
+				})
+			}
+			if len(change.Replacements) > 0 {
+				fix.Changes = append(fix.Changes, change)
+			}
+		}
+		if len(fix.Changes) > 0 {
+			fixes = append(fixes, fix)
+		}
+	}
+	return fixes
 }
@@ -0,0 +1,370 @@
+package sarif
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSarif(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "results.sarif")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFromFileEmpty(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, ""))
+	if err != nil {
+		t.Errorf("expected no error but received %q", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("expected no runs but received %d", len(runs))
+	}
+}
+
+func TestParseFromFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sarif")
+	if _, err := ParseFromFile(path); err == nil {
+		t.Error("expected an error but received none")
+	}
+}
+
+func TestParseFromFileMultipleRuns(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, `{
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {"driver": {"name": "semgrep", "version": "1.2.3", "rules": [{"id": "no-print", "name": "NoPrint", "shortDescription": {"text": "Avoid print"}, "helpUri": "https://example.com/no-print", "defaultConfiguration": {"level": "warning"}}]}},
+      "results": [
+        {"ruleId": "no-print", "message": {"text": "no print"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "src/main.py"}, "region": {"startLine": 4}}}]},
+        {"ruleId": "no-print", "message": {"text": "suppressed"}, "suppressions": [{"kind": "inSource"}]}
+      ]
+    },
+    {
+      "tool": {"driver": {"name": "Brakeman"}},
+      "results": []
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs but received %d", len(runs))
+	}
+	if runs[0].Tool.Name != "semgrep" || runs[1].Tool.Name != "Brakeman" {
+		t.Errorf("expected tools semgrep and Brakeman but received %s and %s", runs[0].Tool.Name, runs[1].Tool.Name)
+	}
+	if len(runs[0].Results) != 1 || len(runs[1].Results) != 0 {
+		t.Fatalf("expected 1 and 0 results but received %d and %d", len(runs[0].Results), len(runs[1].Results))
+	}
+
+	if *runs[0].Tool.Version != "1.2.3" {
+		t.Errorf("expected version 1.2.3 but received %s", *runs[0].Tool.Version)
+	}
+	expectedRule := Rule{ID: "no-print", Name: "NoPrint", ShortDescription: "Avoid print", HelpURI: "https://example.com/no-print"}
+	if len(runs[0].Tool.Rules) != 1 || runs[0].Tool.Rules[0] != expectedRule {
+		t.Errorf("expected rule %+v but received %+v", expectedRule, runs[0].Tool.Rules)
+	}
+
+	result := runs[0].Results[0]
+	if result.Level != "warning" {
+		t.Errorf("expected level to default from the rule but received %q", result.Level)
+	}
+	if len(result.Locations) != 1 || result.Locations[0].Filepath != "src/main.py" || *result.Locations[0].StartLine != 4 {
+		t.Errorf("unexpected locations %+v", result.Locations)
+	}
+}
+
+func TestParseFromFileRunCounts(t *testing.T) {
+	result := func(message string) string {
+		return `{"ruleId": "r", "level": "error", "message": {"text": "` + message + `"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "a.py"}, "region": {"startLine": 1}}}]}`
+	}
+	tests := []struct {
+		name             string
+		content          string
+		expectedMessages [][]string
+	}{
+		{"no runs", `{"version": "2.1.0", "runs": []}`, [][]string{}},
+		{"one run", `{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "a"}}, "results": [` + result("a1") + `]}]}`, [][]string{{"a1"}}},
+		// e.g., a merged file of two scans by the same tool, whose results must stay with their own run
+		{"same tool twice", `{"version": "2.1.0", "runs": [
+			{"tool": {"driver": {"name": "a"}}, "results": [` + result("a1") + `, ` + result("a2") + `]},
+			{"tool": {"driver": {"name": "b"}}, "results": []},
+			{"tool": {"driver": {"name": "a"}}, "results": [` + result("a3") + `]}
+		]}`, [][]string{{"a1", "a2"}, {}, {"a3"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, err := ParseFromFile(writeSarif(t, tt.content))
+			if err != nil {
+				t.Fatalf("expected no error but received %q", err)
+			}
+			if len(runs) != len(tt.expectedMessages) {
+				t.Fatalf("expected %d runs but received %d", len(tt.expectedMessages), len(runs))
+			}
+			for i, expected := range tt.expectedMessages {
+				if len(runs[i].Results) != len(expected) {
+					t.Fatalf("expected %d results in run %d but received %d", len(expected), i, len(runs[i].Results))
+				}
+				for j, message := range expected {
+					if runs[i].Results[j].Message != message {
+						t.Errorf("expected result %d of run %d to be %q but received %q", j, i, message, runs[i].Results[j].Message)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseFromFileRunWithoutDriver(t *testing.T) {
+	_, err := ParseFromFile(writeSarif(t, `{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "a"}}, "results": []}, {"tool": {}, "results": []}]}`))
+	if err == nil || !strings.Contains(err.Error(), "run 1") {
+		t.Errorf("expected an error for run 1 but received %v", err)
+	}
+}
+
+func TestParseFromFileCodeFlowsAndRelatedLocations(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, `{
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {"driver": {"name": "CodeQL"}},
+      "results": [
+        {"ruleId": "py/sql-injection", "level": "error", "message": {"text": "tainted query"}},
+        {
+          "ruleId": "py/sql-injection",
+          "level": "error",
+          "message": {"text": "tainted query"},
+          "locations": [{"physicalLocation": {"artifactLocation": {"uri": "src/sink.py"}, "region": {"startLine": 20}}}],
+          "relatedLocations": [
+            {"physicalLocation": {"artifactLocation": {"uri": "src/source.py"}, "region": {"startLine": 3, "endLine": 4}}, "message": {"text": "user input"}},
+            {"message": {"text": "no physical location"}}
+          ],
+          "codeFlows": [
+            {
+              "message": {"text": "flow"},
+              "threadFlows": [
+                {"locations": [
+                  {"location": {"physicalLocation": {"artifactLocation": {"uri": "src/source.py"}, "region": {"startLine": 3}}, "message": {"text": "source"}}},
+                  {"location": {"physicalLocation": {"artifactLocation": {"uri": "src/sink.py"}, "region": {"startLine": 20}}}}
+                ]},
+                {"message": {"text": "second thread"}, "locations": [
+                  {"location": {"physicalLocation": {"artifactLocation": {"uri": "src/other.py"}, "region": {"startLine": 9}}}}
+                ]}
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	results := runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results but received %d", len(results))
+	}
+	if len(results[0].CodeFlows) != 0 || len(results[0].RelatedLocations) != 0 {
+		t.Errorf("expected the first result to have no flows or related locations")
+	}
+
+	related := results[1].RelatedLocations
+	if len(related) != 1 || related[0].Filepath != "src/source.py" || *related[0].StartLine != 3 || *related[0].EndLine != 4 || related[0].Message != "user input" {
+		t.Errorf("unexpected related locations %+v", related)
+	}
+
+	flows := results[1].CodeFlows
+	if len(flows) != 2 {
+		t.Fatalf("expected 2 code flows but received %d", len(flows))
+	}
+	if flows[0].Message != "flow" || len(flows[0].Steps) != 2 || flows[0].Steps[0].Message != "source" || flows[0].Steps[1].Filepath != "src/sink.py" {
+		t.Errorf("unexpected first code flow %+v", flows[0])
+	}
+	if flows[1].Message != "second thread" || len(flows[1].Steps) != 1 || *flows[1].Steps[0].StartLine != 9 {
+		t.Errorf("unexpected second code flow %+v", flows[1])
+	}
+}
+
+func TestParseFromFileFixes(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, `{
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {"driver": {"name": "semgrep"}},
+      "results": [
+        {
+          "ruleId": "no-print",
+          "level": "warning",
+          "message": {"text": "use a logger"},
+          "fixes": [
+            {
+              "description": {"text": "replace print"},
+              "artifactChanges": [
+                {
+                  "artifactLocation": {"uri": "src/main.py"},
+                  "replacements": [
+                    {"deletedRegion": {"startLine": 4, "startColumn": 5, "endLine": 4, "endColumn": 10}, "insertedContent": {"text": "logger.info"}},
+                    {"deletedRegion": {"startLine": 9}},
+                    {"deletedRegion": {"charOffset": 10, "charLength": 2}}
+                  ]
+                },
+                {"artifactLocation": {"uri": "src/empty.py"}, "replacements": []}
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	fixes := runs[0].Results[0].Fixes
+	if len(fixes) != 1 || fixes[0].Description != "replace print" {
+		t.Fatalf("unexpected fixes %+v", fixes)
+	}
+	if len(fixes[0].Changes) != 1 || fixes[0].Changes[0].Filepath != "src/main.py" {
+		t.Fatalf("unexpected changes %+v", fixes[0].Changes)
+	}
+
+	replacements := fixes[0].Changes[0].Replacements
+	if len(replacements) != 2 {
+		t.Fatalf("expected 2 replacements but received %d", len(replacements))
+	}
+	first := replacements[0]
+	if first.StartLine != 4 || first.EndLine != 4 || *first.StartColumn != 5 || *first.EndColumn != 10 || first.InsertedText != "logger.info" {
+		t.Errorf("unexpected first replacement %+v", first)
+	}
+	second := replacements[1]
+	if second.StartLine != 9 || second.EndLine != 9 || second.StartColumn != nil || second.EndColumn != nil || second.InsertedText != "" {
+		t.Errorf("unexpected second replacement %+v", second)
+	}
+}
+
+func TestParseFromFileResolvesURIs(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, `{
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {"driver": {"name": "scanner"}},
+      "originalUriBaseIds": {"SRCROOT": {"uri": "file:///home/runner/work/app/app/"}},
+      "results": [
+        {
+          "ruleId": "rule",
+          "level": "warning",
+          "message": {"text": "message"},
+          "locations": [{"physicalLocation": {"artifactLocation": {"uri": "src/my%20file.py", "uriBaseId": "SRCROOT"}, "region": {"startLine": 1}}}],
+          "relatedLocations": [{"physicalLocation": {"artifactLocation": {"uri": ".\\src\\other.py"}, "region": {"startLine": 2}}}],
+          "codeFlows": [{"threadFlows": [{"locations": [{"location": {"physicalLocation": {"artifactLocation": {"uri": "./src/flow.py", "uriBaseId": "SRCROOT"}, "region": {"startLine": 3}}}}]}]}],
+          "fixes": [{"artifactChanges": [{"artifactLocation": {"uri": "file:///home/runner/work/app/app/src/fix.py"}, "replacements": [{"deletedRegion": {"startLine": 4}}]}]}]
+        }
+      ]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	result := runs[0].Results[0]
+	expected := map[string]string{
+		"location":         "/home/runner/work/app/app/src/my file.py",
+		"related location": "src/other.py",
+		"code flow step":   "/home/runner/work/app/app/src/flow.py",
+		"fix":              "/home/runner/work/app/app/src/fix.py",
+	}
+	got := map[string]string{
+		"location":         result.Locations[0].Filepath,
+		"related location": result.RelatedLocations[0].Filepath,
+		"code flow step":   result.CodeFlows[0].Steps[0].Filepath,
+		"fix":              result.Fixes[0].Changes[0].Filepath,
+	}
+	for name, path := range expected {
+		if got[name] != path {
+			t.Errorf("expected %s path %q but got %q", name, path, got[name])
+		}
+	}
+}
+
+func TestParseFromFileColumns(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, `{
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {"driver": {"name": "scanner"}},
+      "results": [
+        {
+          "ruleId": "rule",
+          "level": "warning",
+          "message": {"text": "message"},
+          "locations": [{"physicalLocation": {"artifactLocation": {"uri": "src/x.py"}, "region": {"startLine": 1, "startColumn": 5, "endColumn": 9}}}]
+        }
+      ]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	location := runs[0].Results[0].Locations[0]
+	if location.StartColumn == nil || *location.StartColumn != 5 || location.EndColumn == nil || *location.EndColumn != 9 {
+		t.Errorf("expected columns 5 to 9 but got %v to %v", location.StartColumn, location.EndColumn)
+	}
+}
+
+func TestParseFromFileSeverityProperties(t *testing.T) {
+	runs, err := ParseFromFile(writeSarif(t, `{
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {"driver": {"name": "scanner", "rules": [
+        {"id": "from-rule", "properties": {"security-severity": "8.8", "problem.severity": "warning"}},
+        {"id": "overridden", "properties": {"security-severity": "9.0"}}
+      ]}},
+      "results": [
+        {"ruleId": "from-rule", "message": {"text": "message"}},
+        {"ruleId": "overridden", "message": {"text": "message"}, "properties": {"security-severity": 2.5, "problem.severity": "recommendation"}},
+        {"ruleId": "without-properties", "message": {"text": "message"}}
+      ]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	tests := []struct {
+		securitySeverity *float64
+		problemSeverity  string
+	}{
+		{floatPointer(8.8), "warning"},
+		{floatPointer(2.5), "recommendation"},
+		{nil, ""},
+	}
+	for i, tt := range tests {
+		result := runs[0].Results[i]
+		if (result.SecuritySeverity == nil) != (tt.securitySeverity == nil) || (result.SecuritySeverity != nil && *result.SecuritySeverity != *tt.securitySeverity) {
+			t.Errorf("%s: expected security-severity %v but got %v", result.RuleID, tt.securitySeverity, result.SecuritySeverity)
+		}
+		if result.ProblemSeverity != tt.problemSeverity {
+			t.Errorf("%s: expected problem.severity %q but got %q", result.RuleID, tt.problemSeverity, result.ProblemSeverity)
+		}
+	}
+}
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,92 @@
+package sarif
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// uriBaseIds may refer to other uriBaseIds; deeper chains are treated as cycles.
+const maxURIBaseIDDepth = 10
+
+var windowsDrivePattern = regexp.MustCompile(`^/?[A-Za-z]:/`)
+
+// Resolves artifact URIs to file paths, using the locations of the run's uriBaseIds.
+type uriResolver struct {
+	baseIDs map[string]rawArtifactLocation
+}
+
+// Resolve a URI (relative to uriBaseID, if set) to a path. URIs relative to an unknown uriBaseId (e.g., %SRCROOT%
+// without originalUriBaseIds) are left relative, as the base is usually the root of the scanned checkout.
+func (resolver uriResolver) resolve(uri string, uriBaseID *string) string {
+	resolved := uriToPath(uri)
+	if IsAbsolutePath(resolved) || uriBaseID == nil {
+		return resolved
+	}
+	if base := resolver.basePath(*uriBaseID, 0); base != "" {
+		return path.Join(base, resolved)
+	}
+	return resolved
+}
+
+func (resolver uriResolver) basePath(uriBaseID string, depth int) string {
+	base, found := resolver.baseIDs[uriBaseID]
+	if !found || base.URI == nil || depth >= maxURIBaseIDDepth {
+		return ""
+	}
+
+	basePath := uriToPath(*base.URI)
+	if IsAbsolutePath(basePath) || base.URIBaseID == nil {
+		return basePath
+	}
+	if parent := resolver.basePath(*base.URIBaseID, depth+1); parent != "" {
+		return path.Join(parent, basePath)
+	}
+	return basePath
+}
+
+// Convert a URI (e.g., file:///home/runner/work/app/src/x.py, ./src/x.py, or src\x%20y.py) to a clean path with
+// forward slashes.
+func uriToPath(uri string) string {
+	decoded := uri
+	if strings.HasPrefix(strings.ToLower(uri), "file:") {
+		if parsed, err := url.Parse(uri); err == nil {
+			decoded = parsed.Path
+			if parsed.Host != "" && parsed.Host != "localhost" {
+				decoded = "//" + parsed.Host + decoded // a network share
+			}
+			if parsed.Opaque != "" { // e.g., file:src/x.py
+				decoded = parsed.Opaque
+				if unescaped, err := url.PathUnescape(decoded); err == nil {
+					decoded = unescaped
+				}
+			}
+		}
+	} else if unescaped, err := url.PathUnescape(uri); err == nil {
+		decoded = unescaped
+	}
+
+	decoded = strings.ReplaceAll(decoded, "\\", "/")
+	if windowsDrivePattern.MatchString(decoded) {
+		decoded = strings.TrimPrefix(decoded, "/")
+	}
+	if decoded == "" {
+		return ""
+	}
+
+	cleaned := path.Clean(decoded)
+	if strings.HasPrefix(decoded, "//") {
+		cleaned = "/" + cleaned // path.Clean collapses the leading slashes of a network share
+	}
+	if cleaned == "." {
+		return ""
+	}
+	return cleaned
+}
+
+// Whether a resolved path is absolute (e.g., /home/runner/work/app/src/x.py or C:/app/src/x.py), rather than relative
+// to the root of the scanned checkout.
+func IsAbsolutePath(filepath string) bool {
+	return strings.HasPrefix(filepath, "/") || windowsDrivePattern.MatchString(filepath)
+}
@@ -0,0 +1,61 @@
+package sarif
+
+import "testing"
+
+func TestURIToPath(t *testing.T) {
+	tests := []struct {
+		uri, expected string
+	}{
+		{"src/x.py", "src/x.py"},
+		{"./src/x.py", "src/x.py"},
+		{"src/../lib/x.py", "lib/x.py"},
+		{"src/my%20file.py", "src/my file.py"},
+		{"src\\windows\\x.py", "src/windows/x.py"},
+		{"file:///home/runner/work/app/app/src/x.py", "/home/runner/work/app/app/src/x.py"},
+		{"file://localhost/home/runner/x.py", "/home/runner/x.py"},
+		{"file:///C:/work/app/src/x%23y.py", "C:/work/app/src/x#y.py"},
+		{"file:src/x.py", "src/x.py"},
+		{"C:\\work\\app\\x.py", "C:/work/app/x.py"},
+		{"file://server/share/x.py", "//server/share/x.py"},
+		{"100%.py", "100%.py"},
+		{"./", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := uriToPath(tt.uri); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestURIResolverResolve(t *testing.T) {
+	stringPointer := func(s string) *string { return &s }
+	resolver := uriResolver{baseIDs: map[string]rawArtifactLocation{
+		"WORKSPACE": {URI: stringPointer("file:///home/runner/work/app/app/")},
+		"SRCROOT":   {URI: stringPointer("services/api/"), URIBaseID: stringPointer("WORKSPACE")},
+		"RELATIVE":  {URI: stringPointer("services/web/")},
+		"CYCLE":     {URI: stringPointer("a/"), URIBaseID: stringPointer("CYCLE")},
+	}}
+
+	tests := []struct {
+		name, uri string
+		uriBaseID *string
+		expected  string
+	}{
+		{"no base", "src/x.py", nil, "src/x.py"},
+		{"absolute base", "src/x.py", stringPointer("WORKSPACE"), "/home/runner/work/app/app/src/x.py"},
+		{"nested base", "src/x.py", stringPointer("SRCROOT"), "/home/runner/work/app/app/services/api/src/x.py"},
+		{"relative base", "src/x.py", stringPointer("RELATIVE"), "services/web/src/x.py"},
+		{"unknown base", "src/x.py", stringPointer("%SRCROOT%"), "src/x.py"},
+		{"absolute uri ignores base", "file:///tmp/x.py", stringPointer("WORKSPACE"), "/tmp/x.py"},
+		{"cyclic base", "x.py", stringPointer("CYCLE"), "a/a/a/a/a/a/a/a/a/a/x.py"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolver.resolve(tt.uri, tt.uriBaseID); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"less-advanced-security/sarif"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Maps the severity signals of sarif results (overrides, security-severity scores, problem.severity, and levels) to
+// the sarif level used for annotations.
+type severityMapping struct {
+	// security-severity scores at or above these thresholds are errors or warnings respectively, others are notes
+	errorThreshold, warningThreshold float64
+	// levels which replace those of every result of a rule (by rule id) or a tool (by tool name)
+	ruleLevels, toolLevels map[string]string
+	// the level of results whose level is missing or unknown
+	defaultLevel string
+}
+
+func parseSeverityMapping(errorThreshold float64, warningThreshold float64, ruleLevels []string, toolLevels []string, defaultLevel string) (severityMapping, error) {
+	if warningThreshold > errorThreshold {
+		return severityMapping{}, errors.Errorf("the security-severity warning threshold (%v) must not exceed the error threshold (%v)", warningThreshold, errorThreshold)
+	}
+	defaultLevel = strings.ToLower(defaultLevel)
+	if !isLevel(defaultLevel) {
+		return severityMapping{}, errors.Errorf("invalid default level %q (must be one of error, warning, note, or none)", defaultLevel)
+	}
+
+	mapping := severityMapping{errorThreshold: errorThreshold, warningThreshold: warningThreshold, defaultLevel: defaultLevel}
+	var err error
+	if mapping.ruleLevels, err = parseLevelOverrides(ruleLevels); err != nil {
+		return severityMapping{}, errors.Wrap(err, "invalid rule level")
+	}
+	if mapping.toolLevels, err = parseLevelOverrides(toolLevels); err != nil {
+		return severityMapping{}, errors.Wrap(err, "invalid tool level")
+	}
+	return mapping, nil
+}
+
+// Choose the level of a result, in order of precedence: a rule override, a tool override, the security-severity
+// score, the problem.severity property, and finally the result's own level.
+func (mapping severityMapping) level(toolName string, result *sarif.Result) string {
+	if level, found := mapping.ruleLevels[result.RuleID]; found {
+		return level
+	}
+	if level, found := mapping.toolLevels[toolName]; found {
+		return level
+	}
+	if result.SecuritySeverity != nil {
+		switch {
+		case *result.SecuritySeverity >= mapping.errorThreshold:
+			return "error"
+		case *result.SecuritySeverity >= mapping.warningThreshold:
+			return "warning"
+		}
+		return "note"
+	}
+	switch strings.ToLower(result.ProblemSeverity) {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	case "recommendation":
+		return "note"
+	}
+	if isLevel(strings.ToLower(result.Level)) {
+		return strings.ToLower(result.Level)
+	}
+	return mapping.defaultLevel
+}
+
+// Set the level of every result in the runs.
+func (mapping severityMapping) mapRuns(runs []*sarif.Run) {
+	for _, run := range runs {
+		var toolName string
+		if run.Tool != nil {
+			toolName = run.Tool.Name
+		}
+		for _, result := range run.Results {
+			result.Level = mapping.level(toolName, result)
+		}
+	}
+}
+
+// Parse level overrides of the form name=level (e.g., py/sql-injection=error).
+func parseLevelOverrides(overrides []string) (map[string]string, error) {
+	nameToLevel := make(map[string]string)
+	for _, override := range overrides {
+		separator := strings.LastIndex(override, "=")
+		if separator <= 0 {
+			return nil, errors.Errorf("invalid level override %q (must be of the form name=level)", override)
+		}
+		name, level := strings.TrimSpace(override[:separator]), strings.ToLower(strings.TrimSpace(override[separator+1:]))
+		if !isLevel(level) {
+			return nil, errors.Errorf("invalid level %q in override %q (must be one of error, warning, note, or none)", level, override)
+		}
+		nameToLevel[name] = level
+	}
+	return nameToLevel, nil
+}
+
+// Whether a string is a sarif level (https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html#_Toc34317648).
+func isLevel(level string) bool {
+	switch level {
+	case "error", "warning", "note", "none":
+		return true
+	}
+	return false
+}
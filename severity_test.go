@@ -0,0 +1,88 @@
+package main
+
+import (
+	"less-advanced-security/sarif"
+	"testing"
+)
+
+func TestSeverityMappingLevel(t *testing.T) {
+	score := func(value float64) *float64 { return &value }
+	mapping, err := parseSeverityMapping(7.0, 4.0, []string{"overridden-rule=note"}, []string{"quiet-tool=none"}, "warning")
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+
+	tests := []struct {
+		name     string
+		toolName string
+		result   sarif.Result
+		expected string
+	}{
+		{"rule override", "quiet-tool", sarif.Result{RuleID: "overridden-rule", Level: "error"}, "note"},
+		{"tool override", "quiet-tool", sarif.Result{RuleID: "rule", Level: "error", SecuritySeverity: score(9.8)}, "none"},
+		{"critical security-severity", "scanner", sarif.Result{Level: "note", SecuritySeverity: score(9.8)}, "error"},
+		{"security-severity at the error threshold", "scanner", sarif.Result{SecuritySeverity: score(7.0)}, "error"},
+		{"medium security-severity", "scanner", sarif.Result{Level: "error", SecuritySeverity: score(5.3)}, "warning"},
+		{"low security-severity", "scanner", sarif.Result{Level: "error", SecuritySeverity: score(1.0)}, "note"},
+		{"problem.severity error", "scanner", sarif.Result{Level: "note", ProblemSeverity: "error"}, "error"},
+		{"problem.severity recommendation", "scanner", sarif.Result{Level: "error", ProblemSeverity: "Recommendation"}, "note"},
+		{"unknown problem.severity", "scanner", sarif.Result{Level: "error", ProblemSeverity: "critical"}, "error"},
+		{"result level", "scanner", sarif.Result{Level: "Note"}, "note"},
+		{"unknown level", "scanner", sarif.Result{Level: "critical"}, "warning"},
+		{"missing level", "scanner", sarif.Result{}, "warning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapping.level(tt.toolName, &tt.result); got != tt.expected {
+				t.Errorf("expected level %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSeverityMappingMapRuns(t *testing.T) {
+	mapping, err := parseSeverityMapping(7.0, 4.0, nil, []string{"scanner=error"}, "note")
+	if err != nil {
+		t.Fatalf("expected no error but received %q", err)
+	}
+	fromScanner := &sarif.Result{Level: "note"}
+	withoutTool := &sarif.Result{Level: "unknown"}
+
+	mapping.mapRuns([]*sarif.Run{
+		{Tool: &sarif.Tool{Name: "scanner"}, Results: []*sarif.Result{fromScanner}},
+		{Results: []*sarif.Result{withoutTool}},
+	})
+	if fromScanner.Level != "error" {
+		t.Errorf("expected level error but got %q", fromScanner.Level)
+	}
+	if withoutTool.Level != "note" {
+		t.Errorf("expected level note but got %q", withoutTool.Level)
+	}
+}
+
+func TestParseSeverityMapping(t *testing.T) {
+	tests := []struct {
+		name                             string
+		errorThreshold, warningThreshold float64
+		ruleLevels, toolLevels           []string
+		defaultLevel                     string
+		expectError                      bool
+	}{
+		{"defaults", 7.0, 4.0, nil, nil, "warning", false},
+		{"uppercase levels", 7.0, 4.0, []string{"py/sql-injection = Error"}, []string{"semgrep=NOTE"}, "Note", false},
+		{"rule id containing an equals sign", 7.0, 4.0, []string{"a=b=error"}, nil, "warning", false},
+		{"thresholds out of order", 4.0, 7.0, nil, nil, "warning", true},
+		{"invalid default level", 7.0, 4.0, nil, nil, "critical", true},
+		{"rule level without a name", 7.0, 4.0, []string{"=error"}, nil, "warning", true},
+		{"rule level without a level", 7.0, 4.0, []string{"rule"}, nil, "warning", true},
+		{"invalid tool level", 7.0, 4.0, nil, []string{"semgrep=critical"}, "warning", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSeverityMapping(tt.errorThreshold, tt.warningThreshold, tt.ruleLevels, tt.toolLevels, tt.defaultLevel)
+			if (err != nil) != tt.expectError {
+				t.Errorf("expected error %t but got %v", tt.expectError, err)
+			}
+		})
+	}
+}
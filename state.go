@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"less-advanced-security/github"
+
+	"github.com/pkg/errors"
+)
+
+// State shared between the start, finish, and fail commands, which run as separate processes around a scan.
+type runState struct {
+	// IDs of the check runs created by start, by check name
+	CheckRuns map[string]int64 `json:"check_runs"`
+	// the commit the check runs were started on
+	CheckRunsSHA string `json:"check_runs_sha,omitempty"`
+	// how far posting each check got, by check name, so that a failed run can be resumed by running it again
+	Progress map[string]checkProgress `json:"progress,omitempty"`
+}
+
+// How far posting a check got.
+type checkProgress struct {
+	github.PostingProgress
+	// whether the check's suggestions were posted, once all of its annotations were
+	Suggested bool `json:"suggested,omitempty"`
+}
+
+// Load the state at path, returning an empty state if no file exists there.
+func loadRunState(path string) (*runState, error) {
+	state := &runState{CheckRuns: make(map[string]int64), Progress: make(map[string]checkProgress)}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read state from %q", path)
+	}
+	if err := json.Unmarshal(contents, state); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse state from %q", path)
+	}
+	if state.CheckRuns == nil {
+		state.CheckRuns = make(map[string]int64)
+	}
+	if state.Progress == nil {
+		state.Progress = make(map[string]checkProgress)
+	}
+	return state, nil
+}
+
+func (state *runState) save(path string) error {
+	contents, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize state")
+	}
+	if err := os.WriteFile(path, contents, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write state to %q", path)
+	}
+	return nil
+}
+
+// Remove the state file once nothing in it is needed, and otherwise save it.
+func (state *runState) saveOrRemove(path string) error {
+	if len(state.CheckRuns) > 0 || len(state.Progress) > 0 {
+		return state.save(path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove state at %q", path)
+	}
+	return nil
+}
+
+// The progress of a previous attempt to post a check's annotations, if any.
+func (state *runState) resumeFrom(checkName string) *github.PostingProgress {
+	progress, ok := state.Progress[checkName]
+	if !ok {
+		return nil
+	}
+	return &progress.PostingProgress
+}
+
+// Forget check runs started on another commit (e.g., by a start whose scan never finished in a reused workspace), so
+// that they are not completed with this commit's findings, returning how many were forgotten.
+func (state *runState) forCommit(sha string) int {
+	if state.CheckRunsSHA == sha {
+		return 0
+	}
+	forgotten := len(state.CheckRuns)
+	state.CheckRuns, state.CheckRunsSHA = make(map[string]int64), sha
+	return forgotten
+}
+
+// Find the started check run for a check. A check run started under a different name is still used when it is the
+// only one started and there is only one check to post (e.g., the check is named after the tool in the sarif).
+func (state *runState) checkRunFor(checkName string, checkCount int) (name string, id int64) {
+	if id, ok := state.CheckRuns[checkName]; ok {
+		return checkName, id
+	}
+	if len(state.CheckRuns) == 1 && checkCount == 1 {
+		for name, id := range state.CheckRuns {
+			return name, id
+		}
+	}
+	return "", 0
+}
+
+// A check run started for a check, which the check completes under the started name (so that a required status check
+// of that name completes, even when the check would otherwise be named after its tool).
+type startedCheckRun struct {
+	name string
+	id   int64
+}
+
+// Match checks to the check runs started for them, completing each started check run with at most one check.
+func (state *runState) startedCheckRuns(checks []*check) map[*check]startedCheckRun {
+	started := make(map[*check]startedCheckRun)
+	used := make(map[string]bool)
+	for _, check := range checks {
+		if name, id := state.checkRunFor(check.name, len(checks)); id != 0 && !used[name] {
+			started[check] = startedCheckRun{name: name, id: id}
+			used[name] = true
+		}
+	}
+	return started
+}
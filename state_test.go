@@ -0,0 +1,152 @@
+package main
+
+import (
+	"less-advanced-security/github"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadRunState(path)
+	if err != nil {
+		t.Fatalf("expected no error for a missing state file but received %q", err)
+	}
+	if len(state.CheckRuns) != 0 {
+		t.Errorf("expected no check runs but got %v", state.CheckRuns)
+	}
+
+	state.CheckRuns["semgrep"] = 42
+	if err := state.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadRunState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CheckRuns["semgrep"] != 42 {
+		t.Errorf("expected check run 42 for semgrep but got %v", loaded.CheckRuns)
+	}
+}
+
+func TestLoadRunStateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRunState(path); err == nil {
+		t.Error("expected an error for invalid state but received none")
+	}
+}
+
+func TestCheckRunFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		checkRuns    map[string]int64
+		checkName    string
+		checkCount   int
+		expectedName string
+		expectedID   int64
+	}{
+		{"matching name", map[string]int64{"scan": 1, "semgrep": 2}, "semgrep", 2, "semgrep", 2},
+		{"only started and only check", map[string]int64{"scan": 1}, "semgrep", 1, "scan", 1},
+		{"only started among checks", map[string]int64{"scan": 1}, "semgrep", 2, "", 0},
+		{"nothing started", map[string]int64{}, "semgrep", 1, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &runState{CheckRuns: tt.checkRuns}
+			name, id := state.checkRunFor(tt.checkName, tt.checkCount)
+			if name != tt.expectedName || id != tt.expectedID {
+				t.Errorf("expected %q (%d) but got %q (%d)", tt.expectedName, tt.expectedID, name, id)
+			}
+		})
+	}
+}
+
+func TestRunStateProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadRunState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.resumeFrom("semgrep") != nil {
+		t.Error("expected nothing to resume without progress")
+	}
+
+	progress := github.PostingProgress{HeadSHA: "abc123", CheckName: "semgrep", CheckRunID: 42, PostedPages: 7, Fingerprint: "abc"}
+	state.Progress["semgrep"] = checkProgress{PostingProgress: progress, Suggested: true}
+	if err := state.saveOrRemove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadRunState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed := loaded.resumeFrom("semgrep"); resumed == nil || *resumed != progress {
+		t.Errorf("expected to resume from %v but got %v", progress, resumed)
+	}
+	if !loaded.Progress["semgrep"].Suggested {
+		t.Error("expected suggestions to be recorded as posted")
+	}
+
+	loaded.Progress = make(map[string]checkProgress)
+	if err := loaded.saveOrRemove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the empty state to be removed but received %v", err)
+	}
+}
+
+func TestStartedCheckRuns(t *testing.T) {
+	semgrep := &check{name: "semgrep"}
+	codeql := &check{name: "codeql"}
+
+	tests := []struct {
+		name      string
+		checkRuns map[string]int64
+		checks    []*check
+		expected  map[*check]startedCheckRun
+	}{
+		{"matching names", map[string]int64{"semgrep": 1, "codeql": 2}, []*check{semgrep, codeql}, map[*check]startedCheckRun{semgrep: {"semgrep", 1}, codeql: {"codeql", 2}}},
+		{"started under another name keeps that name", map[string]int64{"scan": 1}, []*check{semgrep}, map[*check]startedCheckRun{semgrep: {"scan", 1}}},
+		{"unmatched checks", map[string]int64{"scan": 1}, []*check{semgrep, codeql}, map[*check]startedCheckRun{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &runState{CheckRuns: tt.checkRuns}
+			got := state.startedCheckRuns(tt.checks)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v but got %v", tt.expected, got)
+			}
+			for check, expected := range tt.expected {
+				if got[check] != expected {
+					t.Errorf("expected %s to complete %v but got %v", check.name, expected, got[check])
+				}
+			}
+		})
+	}
+}
+
+func TestRunStateForCommit(t *testing.T) {
+	state := &runState{CheckRuns: map[string]int64{"scan": 1}, CheckRunsSHA: "abc123"}
+	if forgotten := state.forCommit("abc123"); forgotten != 0 || state.CheckRuns["scan"] != 1 {
+		t.Errorf("expected to keep check runs started on the same commit but forgot %d (%v)", forgotten, state.CheckRuns)
+	}
+
+	if forgotten := state.forCommit("def456"); forgotten != 1 || len(state.CheckRuns) != 0 {
+		t.Errorf("expected to forget check runs started on another commit but forgot %d (%v)", forgotten, state.CheckRuns)
+	}
+	if _, id := state.checkRunFor("semgrep", 1); id != 0 {
+		t.Errorf("expected no started check run for another commit but got %d", id)
+	}
+	if state.CheckRunsSHA != "def456" {
+		t.Errorf("expected check runs to be recorded for def456 but got %q", state.CheckRunsSHA)
+	}
+}